@@ -0,0 +1,111 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultGamePort is the port game servers listen on by default.
+	DefaultGamePort = 28001
+	// DefaultMasterPort is the port master servers listen on by default.
+	DefaultMasterPort = 28000
+)
+
+// NormalizeAddress turns a user entered address into the canonical
+// "host:port" form used throughout the package, so that "Host", " host "
+// and "tribes://host:28001/" all compare equal.  A scheme-like prefix and
+// trailing slashes are removed, the host is lower cased and defaultPort is
+// added when no port is given.  The host is not resolved.
+func NormalizeAddress(address string, defaultPort int) (normalized string, err error) {
+	hostPort := strings.TrimSpace(address)
+	if i := strings.Index(hostPort, "://"); i >= 0 {
+		hostPort = hostPort[i+3:]
+	}
+	hostPort = strings.TrimRight(hostPort, "/")
+
+	host, port := hostPort, strconv.Itoa(defaultPort)
+	if strings.Count(hostPort, ":") == 1 || strings.HasPrefix(hostPort, "[") {
+		host, port, err = net.SplitHostPort(hostPort)
+		if err != nil {
+			return "", fmt.Errorf("t1net.NormalizeAddress: Invalid address %q: %v", address, err)
+		}
+	}
+	if len(host) == 0 {
+		return "", fmt.Errorf("t1net.NormalizeAddress: Missing host in %q", address)
+	}
+	if number, err := strconv.ParseUint(port, 10, 16); err != nil || number == 0 {
+		return "", fmt.Errorf("t1net.NormalizeAddress: Invalid port in %q", address)
+	}
+	return net.JoinHostPort(strings.ToLower(host), port), nil
+}
+
+// ResolveAddress normalizes address like NormalizeAddress and replaces the
+// host with the first IPv4 address it resolves to.
+func ResolveAddress(address string, defaultPort int) (resolved string, err error) {
+	normalized, err := NormalizeAddress(address, defaultPort)
+	if err != nil {
+		return
+	}
+	candidates, err := resolveCandidates(context.Background(), nil, normalized, "udp4")
+	if err != nil {
+		return
+	}
+	return candidates[0].String(), nil
+}
+
+// NewGameServerStrict is NewGameServer for addresses that must be valid: it
+// returns an error if address cannot be normalized or its host does not
+// resolve to an IPv4 or IPv6 address, instead of leaving Query to fail.  The
+// host is resolved again on every query unless Resolve is called.
+func NewGameServerStrict(address string) (g *GameServer, err error) {
+	normalized, err := NormalizeAddress(address, DefaultGamePort)
+	if err != nil {
+		return
+	}
+	if _, err = resolveCandidates(context.Background(), nil, normalized, "udp"); err != nil {
+		return
+	}
+	return &GameServer{address: normalized}, nil
+}
+
+// NewMasterServerStrict is NewMasterServer for addresses that must be valid,
+// like NewGameServerStrict.
+func NewMasterServerStrict(address string) (m *MasterServer, err error) {
+	normalized, err := NormalizeAddress(address, DefaultMasterPort)
+	if err != nil {
+		return
+	}
+	if _, err = resolveCandidates(context.Background(), nil, normalized, "udp"); err != nil {
+		return
+	}
+	return &MasterServer{address: normalized}, nil
+}
+
+// normalizeOrKeep returns the normalized address, or address unchanged if it
+// cannot be normalized so that the error surfaces when it is used.
+func normalizeOrKeep(address string, defaultPort int) string {
+	if normalized, err := NormalizeAddress(address, defaultPort); err == nil {
+		return normalized
+	}
+	return address
+}
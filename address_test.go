@@ -0,0 +1,80 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import "testing"
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := map[string]string{
+		"127.0.0.1:28001":             "127.0.0.1:28001",
+		"127.0.0.1":                   "127.0.0.1:28001",
+		" Tribes.Example.COM ":        "tribes.example.com:28001",
+		"tribes://example.com:28005/": "example.com:28005",
+		"udp://10.0.0.1":              "10.0.0.1:28001",
+		"[::1]:28002":                 "[::1]:28002",
+		"::1":                         "[::1]:28001",
+	}
+	for address, expected := range tests {
+		normalized, err := NormalizeAddress(address, DefaultGamePort)
+		if err != nil {
+			t.Errorf("NormalizeAddress(%q): %v", address, err)
+			continue
+		}
+		if normalized != expected {
+			t.Errorf("NormalizeAddress(%q): %s != %s", address, normalized, expected)
+		}
+	}
+
+	for _, address := range []string{"", ":28001", "example.com:0", "example.com:tribes", "example.com:70000"} {
+		if _, err := NormalizeAddress(address, DefaultGamePort); err == nil {
+			t.Errorf("NormalizeAddress(%q): expected an error", address)
+		}
+	}
+
+	if NewGameServer("127.0.0.1").Status().Address != "127.0.0.1:28001" {
+		t.Errorf("NewGameServer() did not add the default port")
+	}
+	if resolved, err := ResolveAddress("localhost", DefaultMasterPort); err != nil || resolved != "127.0.0.1:28000" {
+		t.Errorf("ResolveAddress(localhost): %s, %v", resolved, err)
+	}
+}
+
+func TestNewServerStrict(t *testing.T) {
+	game, err := NewGameServerStrict("localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address := game.Status().Address; address != "localhost:28001" {
+		t.Errorf("game address: %s != localhost:28001", address)
+	}
+	master, err := NewMasterServerStrict("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if master.address != "127.0.0.1:28000" {
+		t.Errorf("master address: %s != 127.0.0.1:28000", master.address)
+	}
+
+	for _, address := range []string{"", "localhost:notaport", "nonexistent.invalid"} {
+		if _, err = NewGameServerStrict(address); err == nil {
+			t.Errorf("NewGameServerStrict(%q): expected an error", address)
+		}
+		if _, err = NewMasterServerStrict(address); err == nil {
+			t.Errorf("NewMasterServerStrict(%q): expected an error", address)
+		}
+	}
+}
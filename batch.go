@@ -0,0 +1,142 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultConcurrency is how many servers QueryAll and ServerBrowser query at
+// once unless told otherwise.
+const defaultConcurrency = 16
+
+// QueryAll queries the game servers at addresses, the usual next step after
+// MasterServer.Servers, with a pool of workers sized by WithConcurrency and
+// started at the rate set by WithRate.  The other options apply to every
+// query.  Each address is a key of either statuses or errs, as given.
+// Addresses not yet queried when ctx is done fail with ctx.Err().
+func QueryAll(ctx context.Context, addresses []string, options ...QueryOption) (statuses map[string]*GameServerStatus, errs map[string]error) {
+	results := make([]GameServerStatus, len(addresses))
+	failures := make([]error, len(addresses))
+	queryEach(ctx, newGameServers(addresses), options, func(i int, status GameServerStatus, err error) {
+		results[i], failures[i] = status, err
+	})
+
+	statuses = make(map[string]*GameServerStatus)
+	errs = make(map[string]error)
+	for i, address := range addresses {
+		if failures[i] != nil {
+			errs[address] = failures[i]
+			continue
+		}
+		statuses[address] = &results[i]
+	}
+	return
+}
+
+// QueryResult is the outcome of a query made by QueryAsync or
+// QueryAllAsync.
+type QueryResult struct {
+	// Address is the address the query was made for, as given.
+	Address string
+	Status  GameServerStatus
+	Err     error
+}
+
+// QueryAllAsync is QueryAll delivering each result on the returned channel
+// as soon as its query completes, so callers can act on fast servers while
+// slow ones are still pending.  The channel is closed once every address
+// has a result.
+func QueryAllAsync(ctx context.Context, addresses []string, options ...QueryOption) <-chan QueryResult {
+	results := make(chan QueryResult, len(addresses))
+	go func() {
+		defer close(results)
+		queryEach(ctx, newGameServers(addresses), options, func(i int, status GameServerStatus, err error) {
+			results <- QueryResult{Address: addresses[i], Status: status, Err: err}
+		})
+	}()
+	return results
+}
+
+// QueryAsync starts QueryStatus and returns a channel that delivers its
+// result and is then closed, so callers can select on many queries at once.
+func (g *GameServer) QueryAsync(ctx context.Context, options ...QueryOption) <-chan QueryResult {
+	result := make(chan QueryResult, 1)
+	go func() {
+		defer close(result)
+		status, err := g.QueryStatus(ctx, options...)
+		result <- QueryResult{Address: g.address, Status: status, Err: err}
+	}()
+	return result
+}
+
+func newGameServers(addresses []string) (servers []*GameServer) {
+	servers = make([]*GameServer, len(addresses))
+	for i, address := range addresses {
+		servers[i] = NewGameServer(address)
+	}
+	return
+}
+
+// queryEach queries every server as QueryAll does and passes the status or
+// error of each to report along with its index.  report is called from
+// several goroutines at once, but once per index.
+func queryEach(ctx context.Context, servers []*GameServer, options []QueryOption, report func(i int, status GameServerStatus, err error)) {
+	o := newQueryOptions(queryOptions{}, options)
+	concurrency := o.concurrency
+	if concurrency < 1 {
+		concurrency = defaultConcurrency
+	}
+	var limiter <-chan time.Time
+	if o.rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / o.rate))
+		defer ticker.Stop()
+		limiter = ticker.C
+	}
+
+	var wg sync.WaitGroup
+	tickets := make(chan struct{}, concurrency)
+	for i, game := range servers {
+		if i > 0 && limiter != nil {
+			select {
+			case <-limiter:
+			case <-ctx.Done():
+			}
+		}
+		select {
+		case tickets <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			for ; i < len(servers); i++ {
+				report(i, GameServerStatus{}, err)
+			}
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, game *GameServer) {
+			defer wg.Done()
+			defer func() { <-tickets }()
+			status, err := game.QueryStatus(ctx, options...)
+			report(i, status, err)
+		}(i, game)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,69 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQueryAll(t *testing.T) {
+	addresses := []string{"127.0.0.1:28966", "127.0.0.1:28965"}
+	for _, address := range addresses {
+		startResponder(t, address, GameServerStatus{Name: address, Game: "Tribes", Version: "1.11"})
+	}
+
+	start := time.Now()
+	statuses, errs := QueryAll(context.Background(), append(addresses, "127.0.0.1:28964"),
+		WithQueryTimeout(100*time.Millisecond), WithConcurrency(2), WithRate(20))
+	// Three queries at 20 a second start over at least 100ms.
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("QueryAll() finished in %s, expected at least 100ms", elapsed)
+	}
+	if len(statuses) != 2 || len(errs) != 1 {
+		t.Fatalf("QueryAll(): %d statuses, %d errors", len(statuses), len(errs))
+	}
+	for _, address := range addresses {
+		if status := statuses[address]; status == nil || status.Name != address {
+			t.Errorf("statuses[%s]: %+v", address, status)
+		}
+	}
+	if errs["127.0.0.1:28964"] == nil {
+		t.Errorf("errs: %v", errs)
+	}
+
+	results := make(map[string]QueryResult)
+	for result := range QueryAllAsync(context.Background(), append(addresses, "127.0.0.1:28964"), WithQueryTimeout(100*time.Millisecond)) {
+		results[result.Address] = result
+	}
+	if len(results) != 3 || results[addresses[0]].Status.Name != addresses[0] || results["127.0.0.1:28964"].Err == nil {
+		t.Errorf("QueryAllAsync(): %+v", results)
+	}
+
+	result := <-NewGameServer(addresses[1]).QueryAsync(context.Background(), WithQueryTimeout(time.Second))
+	if result.Err != nil || result.Status.Name != addresses[1] || result.Address != addresses[1] {
+		t.Errorf("game.QueryAsync(): %+v", result)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	statuses, errs = QueryAll(ctx, addresses)
+	if len(statuses) != 0 || errs[addresses[0]] != context.Canceled || errs[addresses[1]] != context.Canceled {
+		t.Errorf("QueryAll() with a cancelled context: %v, %v", statuses, errs)
+	}
+}
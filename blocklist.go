@@ -0,0 +1,100 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sync"
+)
+
+// Blocklist excludes servers from aggregated lists by address pattern or
+// server name.  It is safe for concurrent use.
+type Blocklist struct {
+	mutex     sync.RWMutex
+	addresses []string
+	names     []*regexp.Regexp
+}
+
+// AddAddress blocks addresses matching pattern, using path.Match syntax
+// (for example "10.0.0.*:*" or "1.2.3.4:28001").
+func (b *Blocklist) AddAddress(pattern string) (err error) {
+	if _, err = path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("t1net.Blocklist.AddAddress: %q: %w", pattern, err)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.addresses = append(b.addresses, pattern)
+	return
+}
+
+// AddName blocks servers whose name matches the regular expression expr.
+func (b *Blocklist) AddName(expr string) (err error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("t1net.Blocklist.AddName: %w", err)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.names = append(b.names, re)
+	return
+}
+
+func (b *Blocklist) BlocksAddress(address string) bool {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	for _, pattern := range b.addresses {
+		if matched, _ := path.Match(pattern, address); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// BlocksStatus reports whether the status is blocked by its address or name.
+func (b *Blocklist) BlocksStatus(status *GameServerStatus) bool {
+	if b.BlocksAddress(status.Address) {
+		return true
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	for _, re := range b.names {
+		if re.MatchString(status.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterServers returns the servers whose addresses are not blocked, such
+// as the result of MergeServerLists.
+func (b *Blocklist) FilterServers(servers []string) (allowed []string) {
+	for _, server := range servers {
+		if !b.BlocksAddress(server) {
+			allowed = append(allowed, server)
+		}
+	}
+	return
+}
+
+func NewBlocklist() *Blocklist {
+	return &Blocklist{}
+}
@@ -0,0 +1,50 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import "testing"
+
+func TestBlocklist(t *testing.T) {
+	blocklist := NewBlocklist()
+	if err := blocklist.AddAddress("10.0.0.*:*"); err != nil {
+		t.Fatal(err)
+	}
+	if err := blocklist.AddAddress("1.2.3.4:28002"); err != nil {
+		t.Fatal(err)
+	}
+	if err := blocklist.AddName("(?i)free\\s+admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := blocklist.AddAddress("["); err == nil {
+		t.Error("expected an error for a bad pattern")
+	}
+	if err := blocklist.AddName("("); err == nil {
+		t.Error("expected an error for a bad expression")
+	}
+
+	servers := blocklist.FilterServers([]string{"10.0.0.5:28001", "1.2.3.4:28001", "1.2.3.4:28002"})
+	if len(servers) != 1 || servers[0] != "1.2.3.4:28001" {
+		t.Errorf("blocklist.FilterServers(): %v", servers)
+	}
+
+	if !blocklist.BlocksStatus(&GameServerStatus{Address: "5.6.7.8:28001", Name: "FREE  ADMIN here"}) {
+		t.Error("status with a blocked name was not blocked")
+	}
+	if blocklist.BlocksStatus(&GameServerStatus{Address: "5.6.7.8:28001", Name: "Normal"}) {
+		t.Error("status was blocked")
+	}
+}
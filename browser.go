@@ -0,0 +1,361 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ServerBrowser keeps a view of every game server listed by a set of master
+// servers, the glue behind a server browser.  Refresh queries the masters,
+// then queries every listed server with bounded parallelism.  Servers keep
+// their last successful status while they stay listed, so a dropped reply
+// does not remove them from the view.  Servers the masters stop listing
+// keep their health, counting every Refresh as a failure, until they are
+// retired.  It is safe for concurrent use.
+type ServerBrowser struct {
+	mutex       sync.RWMutex
+	refresh     sync.Mutex
+	masters     []*MasterServer
+	extra       []string
+	servers     map[string]*GameServer
+	delisted    map[string]*GameServer
+	addresses   []string
+	concurrency int
+	timeout     time.Duration
+	blocklist   *Blocklist
+	simulation  *Simulation
+	resolver    Resolver
+	health      map[string]Health
+	thresholds  HealthThresholds
+	lastRefresh time.Time
+}
+
+// BrowserEntry is a listed server's last successful status, which is empty
+// if it has never answered, and its health.
+type BrowserEntry struct {
+	Status GameServerStatus
+	Health Health
+}
+
+// SetConcurrency sets how many game servers Refresh queries at once.  The
+// default is 16.
+func (b *ServerBrowser) SetConcurrency(concurrency int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.concurrency = concurrency
+}
+
+// SetTimeout sets the timeout of every master and game server query.  Zero
+// uses each server's own timeout.
+func (b *ServerBrowser) SetTimeout(timeout time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.timeout = timeout
+}
+
+// SetBlocklist excludes the servers blocklist blocks, by address from the
+// queries and by name from Statuses.  A nil blocklist blocks nothing.
+func (b *ServerBrowser) SetBlocklist(blocklist *Blocklist) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.blocklist = blocklist
+}
+
+// SetSimulation makes Refresh answer every query from s instead of the
+// network, as WithSimulation does.  A nil s goes back to the network.
+func (b *ServerBrowser) SetSimulation(s *Simulation) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.simulation = s
+}
+
+// SetResolver makes Refresh look up host names with resolver, both to query
+// the servers, as WithResolver does, and to merge the masters' lists.  A nil
+// resolver uses net.DefaultResolver.
+func (b *ServerBrowser) SetResolver(resolver Resolver) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.resolver = resolver
+}
+
+// SetHealthThresholds sets the thresholds Refresh moves servers between
+// health states with.  Zero fields use DefaultHealthThresholds.
+func (b *ServerBrowser) SetHealthThresholds(thresholds HealthThresholds) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.thresholds = thresholds
+}
+
+// AddServer adds a game server to query whether or not a master lists it,
+// such as a favorite.
+func (b *ServerBrowser) AddServer(address string) {
+	address = normalizeOrKeep(address, DefaultGamePort)
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.extra = append(b.extra, address)
+}
+
+// Masters returns the master servers, to inspect their last replies or
+// change their settings.
+func (b *ServerBrowser) Masters() (masters []*MasterServer) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	masters = make([]*MasterServer, len(b.masters))
+	copy(masters, b.masters)
+	return
+}
+
+// Refresh queries the masters, merges their lists with the servers added
+// with AddServer and queries every server on the merged list.  Servers that
+// are no longer listed are not queried again; a master that fails keeps
+// listing the servers of its last reply.  Failed game server queries are
+// left to GameServer.LastError; Refresh only fails if ctx is done or every
+// master failed while there are no added servers to fall back on.
+// Concurrent calls run one at a time.
+func (b *ServerBrowser) Refresh(ctx context.Context) (err error) {
+	return b.refreshEach(ctx, nil)
+}
+
+// refreshEach is Refresh passing the result of each game server query to
+// report, if it is not nil, as soon as it completes.  report is called from
+// several goroutines at once.
+func (b *ServerBrowser) refreshEach(ctx context.Context, report func(result QueryResult)) (err error) {
+	b.refresh.Lock()
+	defer b.refresh.Unlock()
+
+	b.mutex.RLock()
+	masters, extra := b.masters, b.extra
+	concurrency, timeout, blocklist, resolver := b.concurrency, b.timeout, b.blocklist, b.resolver
+	options := []QueryOption{WithQueryTimeout(timeout)}
+	if b.simulation != nil {
+		options = append(options, WithSimulation(b.simulation))
+	}
+	if resolver != nil {
+		options = append(options, WithResolver(resolver))
+	}
+	b.mutex.RUnlock()
+
+	// A master that fails keeps its last list, so one dropped reply does
+	// not remove its servers from the view.
+	lists := [][]string{extra}
+	answered := 0
+	var masterErr error
+	for _, master := range masters {
+		if err = master.QueryWith(ctx, options...); err != nil {
+			if ctxErr := contextDone(ctx); ctxErr != nil {
+				return ctxErr
+			}
+			masterErr = err
+		} else {
+			answered++
+		}
+		lists = append(lists, master.Servers())
+	}
+	if answered == 0 && len(extra) == 0 && masterErr != nil {
+		return fmt.Errorf("t1net.ServerBrowser.Refresh: Every master failed: %w", masterErr)
+	}
+
+	addresses, err := MergeServerListsContext(ctx, resolver, CollapseSameAddress, lists...)
+	if err != nil {
+		return
+	}
+	if blocklist != nil {
+		addresses = blocklist.FilterServers(addresses)
+	}
+
+	b.mutex.Lock()
+	servers := make(map[string]*GameServer, len(addresses))
+	list := make([]*GameServer, len(addresses))
+	for i, address := range addresses {
+		game, ok := b.servers[address]
+		if !ok {
+			game, ok = b.delisted[address]
+		}
+		if !ok {
+			game = NewGameServer(address)
+		}
+		servers[address], list[i] = game, game
+	}
+	delisted := make(map[string]*GameServer)
+	for _, previous := range []map[string]*GameServer{b.servers, b.delisted} {
+		for address, game := range previous {
+			if _, ok := servers[address]; !ok && (blocklist == nil || !blocklist.BlocksAddress(address)) {
+				delisted[address] = game
+			}
+		}
+	}
+	b.servers, b.delisted, b.addresses = servers, delisted, addresses
+	b.mutex.Unlock()
+
+	errs := make([]error, len(list))
+	queryEach(ctx, list, append(options, WithConcurrency(concurrency)), func(i int, status GameServerStatus, err error) {
+		errs[i] = err
+		if report != nil {
+			report(QueryResult{Address: addresses[i], Status: status, Err: err})
+		}
+	})
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := time.Now()
+	health := make(map[string]Health, len(addresses))
+	for i, address := range addresses {
+		h := b.health[address]
+		// Queries cut short by ctx say nothing about the server.
+		if contextDone(ctx) == nil {
+			var cached *CachedFailureError
+			if !errors.As(errs[i], &cached) {
+				h.Record(errs[i] == nil, now, b.thresholds)
+			}
+		}
+		health[address] = h
+	}
+	// Delisted servers are no longer queried and count as failing, so they
+	// are eventually retired.  A retired one is reported by one Refresh and
+	// forgotten by the next.
+	kept := make(map[string]*GameServer, len(delisted))
+	for address, game := range delisted {
+		h, ok := b.health[address]
+		if !ok || h.State == HealthRetired {
+			continue
+		}
+		if contextDone(ctx) == nil {
+			h.Record(false, now, b.thresholds)
+		}
+		health[address], kept[address] = h, game
+	}
+	b.health, b.delisted = health, kept
+	b.lastRefresh = now
+	return contextDone(ctx)
+}
+
+// Entries returns every listed server with its health, and every server no
+// longer listed until the Refresh after it is retired, sorted by address.
+// With states given, only servers in one of them are returned.  Servers the
+// blocklist blocks by name are left out.
+func (b *ServerBrowser) Entries(states ...HealthState) (entries []BrowserEntry) {
+	b.mutex.RLock()
+	servers := make(map[string]*GameServer, len(b.servers)+len(b.delisted))
+	for address, game := range b.delisted {
+		servers[address] = game
+	}
+	for address, game := range b.servers {
+		servers[address] = game
+	}
+	blocklist := b.blocklist
+	health := make(map[string]Health, len(b.health))
+	for address, h := range b.health {
+		health[address] = h
+	}
+	b.mutex.RUnlock()
+
+	for address, game := range servers {
+		entry := BrowserEntry{Status: game.Status(), Health: health[address]}
+		if len(entry.Health.State) == 0 {
+			entry.Health.State = HealthNew
+		}
+		if len(states) != 0 && !containsState(states, entry.Health.State) {
+			continue
+		}
+		if blocklist != nil && blocklist.BlocksStatus(&entry.Status) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Status.Address < entries[j].Status.Address })
+	return
+}
+
+// SortEntriesByReliability sorts entries from the most to the least
+// reliable server, keeping the order of equally reliable ones.
+func SortEntriesByReliability(entries []BrowserEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Health.Reliability > entries[j].Health.Reliability
+	})
+}
+
+func containsState(states []HealthState, state HealthState) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// Addresses returns the merged server list of the last Refresh.
+func (b *ServerBrowser) Addresses() (addresses []string) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	addresses = make([]string, len(b.addresses))
+	copy(addresses, b.addresses)
+	return
+}
+
+// GameServer returns the GameServer for a listed address, or nil if the
+// last Refresh did not list it.
+func (b *ServerBrowser) GameServer(address string) *GameServer {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.servers[normalizeOrKeep(address, DefaultGamePort)]
+}
+
+// Statuses returns the status of every listed server that has answered at
+// least once, sorted by address, leaving out servers the blocklist blocks
+// by name.
+func (b *ServerBrowser) Statuses() (statuses []GameServerStatus) {
+	b.mutex.RLock()
+	servers, blocklist := b.servers, b.blocklist
+	b.mutex.RUnlock()
+
+	for _, game := range servers {
+		if game.LastSuccess().IsZero() {
+			continue
+		}
+		status := game.Status()
+		if blocklist != nil && blocklist.BlocksStatus(&status) {
+			continue
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Address < statuses[j].Address })
+	return
+}
+
+// LastRefresh returns when the last Refresh completed, or the zero time if
+// none has.
+func (b *ServerBrowser) LastRefresh() (lastRefresh time.Time) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.lastRefresh
+}
+
+// NewServerBrowser returns a ServerBrowser for the master servers at
+// addresses.
+func NewServerBrowser(addresses ...string) *ServerBrowser {
+	b := &ServerBrowser{}
+	for _, address := range addresses {
+		b.masters = append(b.masters, NewMasterServer(address))
+	}
+	return b
+}
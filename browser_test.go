@@ -0,0 +1,177 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// serveMasterList answers master queries on address with a single packet
+// listing servers, all on 127.0.0.1, until the returned closer is called.
+func serveMasterList(t *testing.T, address string, ports ...uint16) (closer func()) {
+	s, err := net.ResolveUDPAddr("udp4", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := net.ListenUDP("udp4", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		readBuffer := make([]byte, 64)
+		for {
+			n, addr, err := c.ReadFromUDP(readBuffer)
+			if err != nil {
+				return
+			}
+			if n != 8 {
+				continue
+			}
+			reply := []byte{0x10, 0x06, 1, 1, readBuffer[4], readBuffer[5], 0x00, 0x66, 1, 'M', 0, 0, byte(len(ports))}
+			for _, port := range ports {
+				reply = append(reply, 6, 127, 0, 0, 1, byte(port), byte(port>>8))
+			}
+			_, _ = c.WriteToUDP(reply, addr)
+		}
+	}()
+
+	return func() {
+		_ = c.Close()
+	}
+}
+
+func TestServerBrowser(t *testing.T) {
+	closer := serveMasterList(t, "127.0.0.1:28973", 28972, 28971)
+	defer closer()
+
+	startResponder(t, "127.0.0.1:28972", GameServerStatus{Name: "Listed", Game: "Tribes", Version: "1.11"})
+	startResponder(t, "127.0.0.1:28970", GameServerStatus{Name: "Favorite", Game: "Tribes", Version: "1.11"})
+
+	browser := NewServerBrowser("127.0.0.1:28973")
+	browser.SetTimeout(200 * time.Millisecond)
+	browser.AddServer("127.0.0.1:28970")
+	err := browser.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if addresses := browser.Addresses(); len(addresses) != 3 {
+		t.Errorf("browser.Addresses(): %v", addresses)
+	}
+	// 28971 does not answer and is left out of the statuses.
+	statuses := browser.Statuses()
+	if len(statuses) != 2 || statuses[0].Name != "Favorite" || statuses[1].Name != "Listed" {
+		t.Fatalf("browser.Statuses(): %+v", statuses)
+	}
+	if game := browser.GameServer("127.0.0.1:28971"); game == nil || game.LastError() == nil {
+		t.Errorf("browser.GameServer(127.0.0.1:28971): %v", game)
+	}
+
+	entries := browser.Entries(HealthNew)
+	if len(entries) != 3 {
+		t.Errorf("browser.Entries(HealthNew): %+v", entries)
+	}
+	if err = browser.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	entries = browser.Entries(HealthHealthy)
+	if len(entries) != 2 || entries[0].Status.Name != "Favorite" || entries[0].Health.Successes != 2 {
+		t.Errorf("browser.Entries(HealthHealthy): %+v", entries)
+	}
+	if entries = browser.Entries(); len(entries) != 3 || entries[1].Health.Failures != 2 {
+		t.Errorf("browser.Entries(): %+v", entries)
+	}
+
+	blocklist := NewBlocklist()
+	if err = blocklist.AddName("^Listed$"); err != nil {
+		t.Fatal(err)
+	}
+	browser.SetBlocklist(blocklist)
+	if statuses = browser.Statuses(); len(statuses) != 1 || statuses[0].Name != "Favorite" {
+		t.Errorf("browser.Statuses() with blocklist: %+v", statuses)
+	}
+	if browser.LastRefresh().IsZero() {
+		t.Errorf("browser.LastRefresh() is zero")
+	}
+}
+
+func TestServerBrowserMasterFails(t *testing.T) {
+	closeFirst := serveMasterList(t, "127.0.0.1:28948", 28946)
+	defer closeFirst()
+	closeSecond := serveMasterList(t, "127.0.0.1:28947", 28945)
+
+	browser := NewServerBrowser("127.0.0.1:28948", "127.0.0.1:28947")
+	browser.SetTimeout(100 * time.Millisecond)
+	if err := browser.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if addresses := browser.Addresses(); len(addresses) != 2 {
+		t.Fatalf("browser.Addresses(): %v", addresses)
+	}
+
+	// The second master stops answering; its servers stay listed.
+	closeSecond()
+	if err := browser.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if browser.Masters()[1].LastError() == nil {
+		t.Errorf("Masters()[1].LastError() == nil")
+	}
+	if addresses := browser.Addresses(); len(addresses) != 2 || addresses[1] != "127.0.0.1:28945" {
+		t.Errorf("browser.Addresses() after a master failed: %v", addresses)
+	}
+}
+
+func TestServerBrowserDelisted(t *testing.T) {
+	closer := serveMasterList(t, "127.0.0.1:28944", 28943)
+	browser := NewServerBrowser("127.0.0.1:28944")
+	browser.SetTimeout(100 * time.Millisecond)
+	browser.SetHealthThresholds(HealthThresholds{DownAfter: 1, RetireAfter: time.Millisecond})
+	if err := browser.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if entries := browser.Entries(HealthDown); len(entries) != 1 || entries[0].Status.Address != "127.0.0.1:28943" {
+		t.Fatalf("browser.Entries(HealthDown): %+v", entries)
+	}
+
+	// The master stops listing 28943, which keeps failing until it retires.
+	closer()
+	closer = serveMasterList(t, "127.0.0.1:28944", 28942)
+	defer closer()
+	time.Sleep(5 * time.Millisecond)
+	if err := browser.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if addresses := browser.Addresses(); len(addresses) != 1 || addresses[0] != "127.0.0.1:28942" {
+		t.Errorf("browser.Addresses(): %v", addresses)
+	}
+	if entries := browser.Entries(HealthRetired); len(entries) != 1 || entries[0].Status.Address != "127.0.0.1:28943" {
+		t.Errorf("browser.Entries(HealthRetired): %+v", entries)
+	}
+
+	// Once reported as retired, it is forgotten.
+	if err := browser.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if entries := browser.Entries(); len(entries) != 1 || entries[0].Status.Address != "127.0.0.1:28942" {
+		t.Errorf("browser.Entries() after retiring: %+v", entries)
+	}
+}
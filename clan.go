@@ -0,0 +1,82 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import "strings"
+
+// ClanResolver returns the clan a player belongs to, or "" if none.
+type ClanResolver func(name string) (clan string)
+
+// ClanTag recognises members of Clan by a tag worn at the start or end of
+// their names, such as "[BE]" or "-=DS=-".  When both Prefix and Suffix are
+// set, a name must have both.
+type ClanTag struct {
+	Clan   string
+	Prefix string
+	Suffix string
+}
+
+// ClanRules resolves clans from a member list and from name tags.  Its
+// Resolve method can be used as a ClanResolver.
+type ClanRules struct {
+	// Members maps normalized player names (see NormalizePlayerName) to
+	// clans, for members who do not wear a tag.  It is checked first.
+	Members map[string]string
+	// Tags are tried in order; the first match wins.
+	Tags []ClanTag
+}
+
+// Resolve returns the clan name belongs to according to the rules, or "" if
+// none matches.
+func (r *ClanRules) Resolve(name string) (clan string) {
+	if clan, ok := r.Members[NormalizePlayerName(name)]; ok {
+		return clan
+	}
+	name = strings.TrimSpace(name)
+	for _, tag := range r.Tags {
+		if len(tag.Prefix) == 0 && len(tag.Suffix) == 0 {
+			continue
+		}
+		if strings.HasPrefix(name, tag.Prefix) && strings.HasSuffix(name, tag.Suffix) && len(name) > len(tag.Prefix)+len(tag.Suffix) {
+			return tag.Clan
+		}
+	}
+	return
+}
+
+// ResolveClans sets the Clan of every player in the status using resolver,
+// for statuses that did not come from a GameServer with a ClanResolver.  A
+// nil resolver clears them.
+func (s *GameServerStatus) ResolveClans(resolver ClanResolver) {
+	for i := range s.Players {
+		s.Players[i].Clan = ""
+		if resolver != nil {
+			s.Players[i].Clan = resolver(s.Players[i].Name)
+		}
+	}
+}
+
+// SetClanResolver makes future queries fill in the Clan of each player using
+// resolver.  Queries call it without holding the server's lock, possibly
+// from several goroutines at once if queries overlap, but LoadReply calls it
+// with the lock held, so it must not call methods on the GameServer.  A nil
+// resolver leaves Clan empty.
+func (g *GameServer) SetClanResolver(resolver ClanResolver) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.clanResolver = resolver
+}
@@ -0,0 +1,82 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestClanRules(t *testing.T) {
+	rules := &ClanRules{
+		Members: map[string]string{"lone wolf": "Blood Eagle"},
+		Tags: []ClanTag{
+			{Clan: "Blood Eagle", Prefix: "[BE]"},
+			{Clan: "Diamond Sword", Prefix: "-=", Suffix: "=-"},
+			{Clan: "Children of the Phoenix", Suffix: "|CotP"},
+		},
+	}
+
+	tests := map[string]string{
+		"[BE]Alpha":     "Blood Eagle",
+		" Lone Wolf ":   "Blood Eagle",
+		"-=Beta=-":      "Diamond Sword",
+		"-=Gamma":       "",
+		"Delta|CotP":    "Children of the Phoenix",
+		"[BE]":          "",
+		"Unaffiliated":  "",
+		"[be]Lowercase": "",
+	}
+	for name, expected := range tests {
+		if clan := rules.Resolve(name); clan != expected {
+			t.Errorf("rules.Resolve(%q): %q != %q", name, clan, expected)
+		}
+	}
+}
+
+func TestGameServerClanResolver(t *testing.T) {
+	status := GameServerStatus{
+		Name:    "Clan Server",
+		Players: []Player{{Name: "[BE]Alpha"}, {Name: "Beta"}},
+	}
+	var buffer bytes.Buffer
+	err := status.WriteReply(&buffer, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules := &ClanRules{Tags: []ClanTag{{Clan: "Blood Eagle", Prefix: "[BE]"}}}
+	game := NewGameServer("127.0.0.1:28001")
+	game.SetClanResolver(rules.Resolve)
+	err = game.LoadReply(buffer.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	players := game.Players()
+	if len(players) != 2 || players[0].Clan != "Blood Eagle" || players[1].Clan != "" {
+		t.Errorf("game.Players(): %v", players)
+	}
+
+	status.ResolveClans(rules.Resolve)
+	if status.Players[0].Clan != "Blood Eagle" || status.Players[1].Clan != "" {
+		t.Errorf("status.Players: %v", status.Players)
+	}
+	status.ResolveClans(nil)
+	if status.Players[0].Clan != "" {
+		t.Errorf("status.Players: %v", status.Players)
+	}
+}
@@ -0,0 +1,280 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// clientQuery identifies a query in flight on a Client by the server it was
+// sent to and its key.
+type clientQuery struct {
+	address string
+	key     uint16
+}
+
+// Client sends game server queries from a single UDP socket and hands each
+// reply to the query with the same server address and key, so thousands of
+// servers can be queried without a socket per query.  Use it with
+// WithClient.  It is safe for concurrent use.
+//
+// The Client picks each query's key itself, avoiding keys still in flight to
+// the same server and keys whose query ended less than the key retention
+// ago.  A reply arriving within the retention after its query gave up is
+// counted as late instead of being mistaken for the reply to a newer query.
+type Client struct {
+	mutex     sync.Mutex
+	conn      *net.UDPConn
+	pending   map[clientQuery]chan []byte
+	expired   map[clientQuery]expiredQuery
+	retention time.Duration
+	pruned    time.Time
+	stats     ClientStats
+	done      chan struct{}
+}
+
+// expiredQuery records when a query that gave up was sent and until when
+// its key stays reserved.
+type expiredQuery struct {
+	sent    time.Time
+	expires time.Time
+}
+
+// defaultKeyRetention is how long a Client keeps the key of a query that
+// gave up reserved unless told otherwise.
+const defaultKeyRetention = 30 * time.Second
+
+// ClientStats counts what a Client has sent and received.
+type ClientStats struct {
+	// InFlight is the number of queries waiting for a reply.
+	InFlight int
+	// Retained is the number of keys reserved after their query gave up.
+	Retained  int
+	Sent      uint64
+	Answered  uint64
+	TimedOut  uint64
+	Cancelled uint64
+	// Late counts replies that arrived after their query gave up but
+	// within the key retention.
+	Late uint64
+	// MaxLateness is the longest time after sending its query that a late
+	// reply arrived, a hint for the timeout to use.
+	MaxLateness time.Duration
+	// Unmatched counts replies that matched no query, current or retained.
+	Unmatched uint64
+}
+
+// Stats returns the client's counters.
+func (c *Client) Stats() (stats ClientStats) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	stats = c.stats
+	stats.InFlight = len(c.pending)
+	stats.Retained = len(c.expired)
+	return
+}
+
+// SetKeyRetention sets how long the key of a query that gave up stays
+// reserved, and its late replies are recognised, after the query ended.
+// The default is 30 seconds.
+func (c *Client) SetKeyRetention(retention time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.retention = retention
+}
+
+// LocalAddr returns the address the client's socket is bound to.
+func (c *Client) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// Close closes the client's socket.  Queries still waiting for a reply fail
+// with net.ErrClosed.
+func (c *Client) Close() (err error) {
+	return c.conn.Close()
+}
+
+// readLoop delivers replies to the queries waiting for them until the
+// socket is closed.
+func (c *Client) readLoop() {
+	defer close(c.done)
+
+	readBuffer := make([]byte, 2048)
+	for {
+		n, addr, err := c.conn.ReadFromUDP(readBuffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		// 0x63 = GameSpy query reply, next two bytes are key
+		if n < 3 || readBuffer[0] != 0x63 {
+			continue
+		}
+		id := clientQuery{address: addr.String(), key: binary.BigEndian.Uint16(readBuffer[1:3])}
+
+		c.mutex.Lock()
+		replies, ok := c.pending[id]
+		delete(c.pending, id)
+		if !ok {
+			c.unmatched(id, time.Now())
+		}
+		c.mutex.Unlock()
+		if ok {
+			replies <- append([]byte(nil), readBuffer[0:n]...)
+		}
+	}
+}
+
+// unmatched counts a reply that matched no query in flight.  It must be
+// called with c's lock held.
+func (c *Client) unmatched(id clientQuery, now time.Time) {
+	expired, ok := c.expired[id]
+	if !ok || now.After(expired.expires) {
+		c.stats.Unmatched++
+		return
+	}
+	c.stats.Late++
+	if lateness := now.Sub(expired.sent); lateness > c.stats.MaxLateness {
+		c.stats.MaxLateness = lateness
+	}
+}
+
+// reserve picks a key for a query to address that is neither in flight nor
+// retained, and registers replies to receive the reply.  It must be called
+// with c's lock held.
+func (c *Client) reserve(address string, replies chan []byte, now time.Time) (id clientQuery, err error) {
+	if now.Sub(c.pruned) >= time.Second {
+		for expiredID, expired := range c.expired {
+			if now.After(expired.expires) {
+				delete(c.expired, expiredID)
+			}
+		}
+		c.pruned = now
+	}
+
+	// Collisions are rare, so a few random picks find a free key.
+	for attempt := 0; attempt < 16; attempt++ {
+		id = clientQuery{address: address, key: uint16(rand.Uint32())}
+		if _, ok := c.pending[id]; ok {
+			continue
+		}
+		if expired, ok := c.expired[id]; ok && !now.After(expired.expires) {
+			continue
+		}
+		delete(c.expired, id)
+		c.pending[id] = replies
+		return
+	}
+	return id, fmt.Errorf("t1net.Client.Query: No free key for %s", address)
+}
+
+// query sends a game server query with a key of the client's choosing to
+// remoteAddr and returns the reply with the same key, the key and the
+// round trip time.  It fails with os.ErrDeadlineExceeded once timeout
+// passes, like a read on a socket.  Packets are recorded in trace, which
+// may be nil.
+func (c *Client) query(ctx context.Context, remoteAddr *net.UDPAddr, timeout time.Duration, trace *QueryTrace) (reply []byte, key uint16, ping time.Duration, err error) {
+	replies := make(chan []byte, 1)
+
+	c.mutex.Lock()
+	sent := time.Now()
+	id, err := c.reserve(remoteAddr.String(), replies, sent)
+	if err != nil {
+		c.mutex.Unlock()
+		return
+	}
+	c.stats.Sent++
+	c.mutex.Unlock()
+	key = id.key
+	defer func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		delete(c.pending, id)
+		switch {
+		case err == nil:
+			c.stats.Answered++
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			c.stats.TimedOut++
+		default:
+			c.stats.Cancelled++
+		}
+		if err != nil && c.retention > 0 {
+			c.expired[id] = expiredQuery{sent: sent, expires: time.Now().Add(c.retention)}
+		}
+	}()
+
+	// 0x62 = GameSpy query request, next two bytes are key
+	request := []byte{0x62, 0x00, 0x00}
+	binary.BigEndian.PutUint16(request[1:], key)
+	trace.packet(true, remoteAddr, request)
+	// The ping is measured from here, after the packet hook has run.
+	sent = time.Now()
+	if _, err = c.conn.WriteToUDP(request, remoteAddr); err != nil {
+		return
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case reply = <-replies:
+		ping = time.Since(sent)
+		trace.packet(false, remoteAddr, reply)
+		return
+	case <-timer.C:
+		return nil, key, 0, os.ErrDeadlineExceeded
+	case <-ctx.Done():
+		return nil, key, 0, ctx.Err()
+	case <-c.done:
+		return nil, key, 0, net.ErrClosed
+	}
+}
+
+// NewClient returns a Client with a socket bound to localAddress, or to any
+// local address if it is empty.
+func NewClient(localAddress string) (c *Client, err error) {
+	var localAddr *net.UDPAddr
+	if len(localAddress) != 0 {
+		localAddr, err = net.ResolveUDPAddr("udp4", localAddress)
+		if err != nil {
+			return
+		}
+	}
+	conn, err := net.ListenUDP("udp4", localAddr)
+	if err != nil {
+		return
+	}
+
+	c = &Client{
+		conn:      conn,
+		pending:   make(map[clientQuery]chan []byte),
+		expired:   make(map[clientQuery]expiredQuery),
+		retention: defaultKeyRetention,
+		done:      make(chan struct{}),
+	}
+	go c.readLoop()
+	return
+}
@@ -0,0 +1,134 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClient(t *testing.T) {
+	addresses := []string{"127.0.0.1:28969", "127.0.0.1:28968"}
+	for _, address := range addresses {
+		startResponder(t, address, GameServerStatus{Name: address, Game: "Tribes", Version: "1.11"})
+	}
+
+	client, err := NewClient("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		for _, address := range addresses {
+			wg.Add(1)
+			go func(address string) {
+				defer wg.Done()
+				game := NewGameServer(address)
+				err := game.QueryWith(context.Background(), WithClient(client), WithQueryTimeout(time.Second))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if game.Name() != address {
+					t.Errorf("game.Name(): %s != %s", game.Name(), address)
+				}
+				if game.Ping() <= 0 {
+					t.Errorf("game.Ping(): %s", game.Ping())
+				}
+			}(address)
+		}
+	}
+	wg.Wait()
+
+	// Nothing answers on 28967.
+	silent, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 28967})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	game := NewGameServer("127.0.0.1:28967")
+	err = game.QueryWith(context.Background(), WithClient(client), WithQueryTimeout(50*time.Millisecond))
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("game.QueryWith(): %v is not a timeout", err)
+	}
+
+	if err = client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	err = game.QueryWith(context.Background(), WithClient(client), WithQueryTimeout(time.Second))
+	if !errors.Is(err, net.ErrClosed) {
+		t.Errorf("game.QueryWith() on a closed client: %v", err)
+	}
+}
+
+func TestClientLateReplies(t *testing.T) {
+	slow, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 28957})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.Close()
+	go func() {
+		readBuffer := make([]byte, 64)
+		n, addr, err := slow.ReadFromUDP(readBuffer)
+		if err != nil || n != 3 {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+		var reply bytes.Buffer
+		status := GameServerStatus{Name: "Slow"}
+		_ = status.WriteReply(&reply, binary.BigEndian.Uint16(readBuffer[1:3]))
+		_, _ = slow.WriteToUDP(reply.Bytes(), addr)
+		// A reply to a query never sent.
+		reply.Reset()
+		_ = status.WriteReply(&reply, binary.BigEndian.Uint16(readBuffer[1:3])+1)
+		_, _ = slow.WriteToUDP(reply.Bytes(), addr)
+	}()
+
+	client, err := NewClient("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	game := NewGameServer("127.0.0.1:28957")
+	err = game.QueryWith(context.Background(), WithClient(client), WithQueryTimeout(20*time.Millisecond))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("game.QueryWith(): %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	stats := client.Stats()
+	if stats.Sent != 1 || stats.TimedOut != 1 || stats.Answered != 0 || stats.InFlight != 0 || stats.Retained != 1 {
+		t.Errorf("Stats(): %+v", stats)
+	}
+	if stats.Late != 1 || stats.MaxLateness < 100*time.Millisecond {
+		t.Errorf("Stats() Late, MaxLateness: %v, %v", stats.Late, stats.MaxLateness)
+	}
+	if stats.Unmatched != 1 {
+		t.Errorf("Stats() Unmatched: %v != 1", stats.Unmatched)
+	}
+}
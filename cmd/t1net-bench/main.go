@@ -0,0 +1,166 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Command t1net-bench sends queries to a master or game server at a fixed
+// rate and reports latency percentiles and loss.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	t1net "github.com/TheKigen/t1net-go"
+)
+
+type result struct {
+	ping time.Duration
+	err  error
+}
+
+func query(master bool, address string, timeout time.Duration) result {
+	if master {
+		m := t1net.NewMasterServer(address)
+		err := m.Query(timeout, "")
+		return result{ping: m.Ping(), err: err}
+	}
+	g := t1net.NewGameServer(address)
+	err := g.Query(timeout, "")
+	return result{ping: g.Ping(), err: err}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted)-1) * p / 100)
+	return sorted[i]
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func main() {
+	game := flag.String("game", "", "game server address to query")
+	master := flag.String("master", "", "master server address to query")
+	qps := flag.Float64("qps", 10, "queries per second across all workers")
+	concurrency := flag.Int("concurrency", 4, "maximum number of queries in flight")
+	duration := flag.Duration("duration", 10*time.Second, "how long to send queries for")
+	timeout := flag.Duration("timeout", 2*time.Second, "per query timeout")
+	flag.Parse()
+
+	if (len(*game) == 0) == (len(*master) == 0) {
+		fmt.Fprintln(os.Stderr, "t1net-bench: exactly one of -game or -master is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *qps <= 0 || *concurrency < 1 {
+		fmt.Fprintln(os.Stderr, "t1net-bench: -qps and -concurrency must be positive")
+		os.Exit(2)
+	}
+
+	isMaster := len(*master) != 0
+	address := *game
+	if isMaster {
+		address = *master
+	}
+
+	rand.Seed(time.Now().UnixNano())
+
+	tickets := make(chan struct{})
+	results := make(chan result, *concurrency)
+
+	var wg sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range tickets {
+				results <- query(isMaster, address, *timeout)
+			}
+		}()
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / *qps))
+		defer ticker.Stop()
+		deadline := time.After(*duration)
+		dropped := 0
+	loop:
+		for {
+			select {
+			case <-deadline:
+				break loop
+			case <-ticker.C:
+				select {
+				case tickets <- struct{}{}:
+				default:
+					dropped++
+				}
+			}
+		}
+		close(tickets)
+		wg.Wait()
+		close(results)
+		if dropped > 0 {
+			fmt.Fprintf(os.Stderr, "t1net-bench: %d queries skipped, all workers busy; raise -concurrency\n", dropped)
+		}
+	}()
+
+	var (
+		pings              []time.Duration
+		sent, lost, failed int
+		lastErr            error
+	)
+	start := time.Now()
+	for r := range results {
+		sent++
+		switch {
+		case r.err == nil:
+			pings = append(pings, r.ping)
+		case isTimeout(r.err):
+			lost++
+		default:
+			failed++
+			lastErr = r.err
+		}
+	}
+	elapsed := time.Since(start)
+
+	sort.Slice(pings, func(i, j int) bool { return pings[i] < pings[j] })
+
+	fmt.Printf("target:    %s\n", address)
+	fmt.Printf("queries:   %d in %s (%.1f/s)\n", sent, elapsed.Round(time.Millisecond), float64(sent)/elapsed.Seconds())
+	if sent > 0 {
+		fmt.Printf("loss:      %d (%.2f%%)\n", lost, float64(lost)*100/float64(sent))
+	}
+	fmt.Printf("errors:    %d\n", failed)
+	if lastErr != nil {
+		fmt.Printf("last err:  %v\n", lastErr)
+	}
+	if len(pings) > 0 {
+		fmt.Printf("latency:   min %s  p50 %s  p90 %s  p99 %s  max %s\n",
+			pings[0], percentile(pings, 50), percentile(pings, 90), percentile(pings, 99), pings[len(pings)-1])
+	}
+}
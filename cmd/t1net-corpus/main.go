@@ -0,0 +1,195 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Command t1net-corpus queries master servers and the game servers they list
+// and saves their raw replies, anonymized, as go-fuzz corpora for
+// t1net.FuzzMasterReply and t1net.FuzzGameReply.  Server addresses in
+// master replies and player names in game replies are replaced with
+// placeholders of the same length, so the packets keep their layout.
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	t1net "github.com/TheKigen/t1net-go"
+	"github.com/TheKigen/t1net-go/config"
+)
+
+// replies returns the reply packets recorded in trace.
+func replies(trace *t1net.QueryTrace) (packets [][]byte) {
+	if trace == nil {
+		return
+	}
+	for _, packet := range trace.Packets {
+		if !packet.Sent {
+			packets = append(packets, packet.Data)
+		}
+	}
+	return
+}
+
+// anonymizeMaster replaces every server address in a master reply packet.
+func anonymizeMaster(packet []byte, servers []string) (anonymized []byte, err error) {
+	mapping := make(map[string]string, len(servers))
+	for i, server := range servers {
+		mapping[server] = fmt.Sprintf("10.%d.%d.%d:28001", (i>>16)&0xff, (i>>8)&0xff, i&0xff)
+	}
+	rewrite, err := t1net.RewriteMasterAddresses(mapping)
+	if err != nil {
+		return
+	}
+	return rewrite(packet), nil
+}
+
+// anonymizeGame replaces every player name in a game reply packet with a
+// placeholder of the same length.
+func anonymizeGame(packet []byte, players []t1net.Player) []byte {
+	anonymized := append([]byte(nil), packet...)
+	for i, player := range players {
+		if len(player.Name) == 0 || len(player.Name) > 255 {
+			continue
+		}
+		placeholder := fmt.Sprintf("Player%d", i+1)
+		if len(placeholder) > len(player.Name) {
+			placeholder = strings.Repeat("x", len(player.Name))
+		}
+		placeholder += strings.Repeat("_", len(player.Name)-len(placeholder))
+
+		prefix := []byte{byte(len(player.Name))}
+		anonymized = bytes.ReplaceAll(anonymized,
+			append(prefix, player.Name...),
+			append(prefix, placeholder...))
+	}
+	return anonymized
+}
+
+// save writes data into dir under its SHA-1, the naming go-fuzz uses.
+func save(dir string, data []byte) (err error) {
+	sum := sha1.Sum(data)
+	return os.WriteFile(filepath.Join(dir, hex.EncodeToString(sum[:])), data, 0644)
+}
+
+func main() {
+	masters := flag.String("masters", "", "comma separated master server addresses to scan, instead of those in -config")
+	configPath := flag.String("config", "", "JSON configuration file listing masters, servers, timeout and limits")
+	out := flag.String("out", "corpus", "directory to write the master/corpus and game/corpus directories into")
+	timeout := flag.Duration("timeout", 2*time.Second, "per query timeout")
+	concurrency := flag.Int("concurrency", 16, "maximum number of game server queries in flight")
+	flag.Parse()
+
+	var cfg config.Config
+	if len(*configPath) != 0 {
+		var err error
+		cfg, err = config.Load(*configPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if len(*masters) != 0 {
+		cfg.Masters = strings.Split(*masters, ",")
+	}
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	if explicit["timeout"] || cfg.Timeout == 0 {
+		cfg.Timeout = config.Duration(*timeout)
+	}
+	configured, err := cfg.AllServers()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(cfg.Masters) == 0 && len(configured) == 0 || *concurrency < 1 {
+		fmt.Fprintln(os.Stderr, "t1net-corpus: -masters or a -config listing masters or servers is required and -concurrency must be positive")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	masterDir := filepath.Join(*out, "master", "corpus")
+	gameDir := filepath.Join(*out, "game", "corpus")
+	for _, dir := range []string{masterDir, gameDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	servers := configured
+	for _, address := range cfg.Masters {
+		master := cfg.NewMasterServer(strings.TrimSpace(address))
+		master.SetTracing(true)
+		if err := master.Query(0, ""); err != nil {
+			log.Printf("master %s: %v", address, err)
+			continue
+		}
+		listed := master.Servers()
+		for _, packet := range replies(master.Trace()) {
+			anonymized, err := anonymizeMaster(packet, listed)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err = save(masterDir, anonymized); err != nil {
+				log.Fatal(err)
+			}
+		}
+		servers = t1net.MergeServerLists(t1net.CollapseSameAddress, servers, listed)
+		log.Printf("master %s: %d servers", address, len(listed))
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+		saved int
+	)
+	tickets := make(chan struct{}, *concurrency)
+	for _, address := range servers {
+		wg.Add(1)
+		tickets <- struct{}{}
+		go func(address string) {
+			defer wg.Done()
+			defer func() { <-tickets }()
+
+			game := cfg.NewGameServer(address)
+			game.SetTracing(true)
+			// Replies that fail to parse cannot be anonymized reliably.
+			if err := game.Query(0, ""); err != nil {
+				return
+			}
+			players := game.Players()
+			for _, packet := range replies(game.Trace()) {
+				if err := save(gameDir, anonymizeGame(packet, players)); err != nil {
+					log.Fatal(err)
+				}
+				mutex.Lock()
+				saved++
+				mutex.Unlock()
+			}
+		}(address)
+	}
+	wg.Wait()
+
+	log.Printf("saved %d game replies from %d servers into %s", saved, len(servers), *out)
+}
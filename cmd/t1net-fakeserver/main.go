@@ -0,0 +1,116 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Command t1net-fakeserver answers Tribes GameSpy queries with data read
+// from a JSON file, for testing browsers, bots and dashboards without a real
+// game server.  Send SIGHUP to reload the file.  With -backend it instead
+// serves cached replies from a real server.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	t1net "github.com/TheKigen/t1net-go"
+)
+
+var defaultStatus = t1net.GameServerStatus{
+	Name:              "t1net Fake Server",
+	Game:              "Tribes",
+	Version:           "1.11",
+	Dedicated:         true,
+	MaxPlayers:        32,
+	CPUSpeed:          3000,
+	Mod:               "base",
+	ServerType:        "CTF",
+	Mission:           "Raindance",
+	Info:              "t1net-fakeserver",
+	TeamScoreHeader:   "Team\tScore",
+	PlayerScoreHeader: "Name\tScore",
+	Teams: []t1net.Team{
+		{Name: "Blood Eagle", Score: "0"},
+		{Name: "Diamond Sword", Score: "0"},
+	},
+}
+
+func loadStatus(path string) (status t1net.GameServerStatus, err error) {
+	if len(path) == 0 {
+		return defaultStatus, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &status)
+	return
+}
+
+func main() {
+	listen := flag.String("listen", ":28001", "comma separated UDP addresses to answer queries on; one without a host listens on IPv4 and IPv6")
+	config := flag.String("config", "", "JSON file containing a t1net.GameServerStatus (default: built-in sample)")
+	logAccess := flag.Bool("log-access", false, "log every request received")
+	backend := flag.String("backend", "", "game server to proxy and cache queries for instead of serving -config")
+	ttl := flag.Duration("ttl", 5*time.Second, "how long to cache the -backend reply")
+	flag.Parse()
+
+	status, err := loadStatus(*config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	responder := t1net.NewQueryResponder(strings.Split(*listen, ",")...)
+	responder.SetStatus(status)
+	if len(*backend) != 0 {
+		responder.SetBackend(t1net.NewGameServer(*backend), *ttl, 2*time.Second)
+	}
+	if *logAccess {
+		responder.SetAccessLog(func(record t1net.AccessRecord) {
+			log.Printf("%s %q in=%d out=%d %s %v", record.Source, record.Opcode, record.BytesIn, record.BytesOut, record.Outcome, record.Err)
+		})
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGHUP {
+				status, err := loadStatus(*config)
+				if err != nil {
+					log.Printf("reload %s: %v", *config, err)
+					continue
+				}
+				responder.SetStatus(status)
+				log.Printf("reloaded %s", *config)
+				continue
+			}
+			_ = responder.Close()
+			return
+		}
+	}()
+
+	log.Printf("answering queries on %s as %q", *listen, status.Name)
+	err = responder.ListenAndServe()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
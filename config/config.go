@@ -0,0 +1,173 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package config loads the JSON file that describes a t1net deployment: the
+// masters and servers to query, how often and with which limits, so daemons
+// and command line tools can share one declarative file instead of flags.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	t1net "github.com/TheKigen/t1net-go"
+)
+
+// Duration is a time.Duration that decodes from a JSON string such as "5s"
+// or from a number of nanoseconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) (err error) {
+	var value interface{}
+	if err = json.Unmarshal(data, &value); err != nil {
+		return
+	}
+	switch value := value.(type) {
+	case float64:
+		*d = Duration(value)
+	case string:
+		var parsed time.Duration
+		parsed, err = time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("config.Duration: %v", err)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("config.Duration: Invalid duration %s", data)
+	}
+	return
+}
+
+// Config is the contents of a configuration file.  Zero fields keep the
+// package defaults.
+type Config struct {
+	// Masters are the master server addresses to take server lists from.
+	Masters []string
+	// Servers are game server addresses to query in addition to those the
+	// masters list.
+	Servers []string
+	// ServerLists are files of further game server addresses, in any format
+	// t1net.ReadServerList accepts.
+	ServerLists []string
+	// Interval is how often to query everything, for daemons.
+	Interval Duration
+	// Timeout is the per query timeout.
+	Timeout Duration
+	// Retries and Backoff are passed to SetRetries.
+	Retries int
+	Backoff Duration
+	// FailureTTL is passed to GameServer.SetFailureTTL.
+	FailureTTL Duration
+	Limits     t1net.Limits
+}
+
+// Load reads and validates the configuration file at path.
+func Load(path string) (config Config, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	config, err = Parse(data)
+	if err != nil {
+		return config, fmt.Errorf("%s: %w", path, err)
+	}
+	return
+}
+
+// Parse decodes and validates a configuration.  Unknown fields are errors,
+// so that misspelt settings are not silently ignored.  Addresses are
+// normalized with t1net.NormalizeAddress.
+func Parse(data []byte) (config Config, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&config); err != nil {
+		return
+	}
+
+	for i, address := range config.Masters {
+		config.Masters[i], err = t1net.NormalizeAddress(address, t1net.DefaultMasterPort)
+		if err != nil {
+			return
+		}
+	}
+	for i, address := range config.Servers {
+		config.Servers[i], err = t1net.NormalizeAddress(address, t1net.DefaultGamePort)
+		if err != nil {
+			return
+		}
+	}
+	if config.Interval < 0 || config.Timeout < 0 || config.Backoff < 0 || config.FailureTTL < 0 || config.Retries < 0 {
+		return config, fmt.Errorf("config.Parse: Negative interval, timeout, retries, backoff or failure TTL")
+	}
+	return
+}
+
+// AllServers returns Servers followed by the addresses in ServerLists, each
+// once.
+func (c *Config) AllServers() (servers []string, err error) {
+	seen := make(map[string]bool)
+	add := func(list []string) {
+		for _, address := range list {
+			if !seen[address] {
+				seen[address] = true
+				servers = append(servers, address)
+			}
+		}
+	}
+
+	add(c.Servers)
+	for _, path := range c.ServerLists {
+		var file *os.File
+		file, err = os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		var list []string
+		list, err = t1net.ReadServerList(file)
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		add(list)
+	}
+	return
+}
+
+// NewGameServer returns a GameServer for address configured with c.
+func (c *Config) NewGameServer(address string) *t1net.GameServer {
+	g := t1net.NewGameServer(address)
+	g.SetTimeout(time.Duration(c.Timeout))
+	g.SetRetries(c.Retries, time.Duration(c.Backoff))
+	g.SetFailureTTL(time.Duration(c.FailureTTL))
+	g.SetLimits(c.Limits)
+	return g
+}
+
+// NewMasterServer returns a MasterServer for address configured with c.
+func (c *Config) NewMasterServer(address string) *t1net.MasterServer {
+	m := t1net.NewMasterServer(address)
+	m.SetTimeout(time.Duration(c.Timeout))
+	m.SetRetries(c.Retries, time.Duration(c.Backoff))
+	m.SetLimits(c.Limits)
+	return m
+}
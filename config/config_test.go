@@ -0,0 +1,81 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	config, err := Parse([]byte(`{
+		"Masters": ["Master.Example.com"],
+		"Servers": ["1.2.3.4", "5.6.7.8:28002"],
+		"Interval": "1m",
+		"Timeout": "2s",
+		"Retries": 2,
+		"Backoff": 500000000,
+		"Limits": {"MaxPlayers": 64}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(config.Masters, " ") != "master.example.com:28000" {
+		t.Errorf("config.Masters: %v", config.Masters)
+	}
+	if strings.Join(config.Servers, " ") != "1.2.3.4:28001 5.6.7.8:28002" {
+		t.Errorf("config.Servers: %v", config.Servers)
+	}
+	if time.Duration(config.Interval) != time.Minute || time.Duration(config.Timeout) != 2*time.Second || time.Duration(config.Backoff) != 500*time.Millisecond {
+		t.Errorf("config durations: %s %s %s", time.Duration(config.Interval), time.Duration(config.Timeout), time.Duration(config.Backoff))
+	}
+
+	game := config.NewGameServer(config.Servers[0])
+	if game.Limits().MaxPlayers != 64 {
+		t.Errorf("game.Limits().MaxPlayers: %d != 64", game.Limits().MaxPlayers)
+	}
+
+	for _, data := range []string{
+		`{"Timeout": "soon"}`,
+		`{"Timout": "2s"}`,
+		`{"Servers": ["1.2.3.4:0"]}`,
+		`{"Retries": -1}`,
+	} {
+		if _, err = Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%s): expected an error", data)
+		}
+	}
+}
+
+func TestAllServers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "servers.txt")
+	err := os.WriteFile(path, []byte("# legacy list\n1.2.3.4:28001\nTBS 9.9.9.9\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := Config{Servers: []string{"1.2.3.4:28001"}, ServerLists: []string{path}}
+	servers, err := config.AllServers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(servers, " ") != "1.2.3.4:28001 9.9.9.9:28001" {
+		t.Errorf("config.AllServers(): %v", servers)
+	}
+}
@@ -0,0 +1,290 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// candidateStagger is how long a query waits for a reply from one address
+// of a multi-homed host before also trying the next one.
+const candidateStagger = 250 * time.Millisecond
+
+// resolveCandidates returns every address the host of address resolves to
+// on network, "udp" for both IPv4 and IPv6, "udp4" or "udp6", in the order
+// resolver returned them.  A nil resolver uses net.DefaultResolver.
+func resolveCandidates(ctx context.Context, resolver Resolver, address string, network string) (candidates []*net.UDPAddr, err error) {
+	var want string
+	switch network {
+	case "udp":
+	case "udp4":
+		want = "IPv4 "
+	case "udp6":
+		want = "IPv6 "
+	default:
+		return nil, fmt.Errorf("t1net.resolveCandidates: Unknown network %q", network)
+	}
+
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return
+	}
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("t1net.resolveCandidates: Invalid port %q", portString)
+	}
+
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	ips, err := resolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return
+	}
+	for _, ip := range ips {
+		ip4 := ip.To4()
+		switch {
+		case ip4 != nil && network != "udp6":
+			candidates = append(candidates, &net.UDPAddr{IP: ip4, Port: int(port)})
+		case ip4 == nil && network != "udp4":
+			candidates = append(candidates, &net.UDPAddr{IP: ip, Port: int(port)})
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("t1net.resolveCandidates: No %saddress for %s", want, host)
+	}
+	return
+}
+
+// resolvedCandidates holds the addresses a server's host resolved to on
+// network, kept by Resolve for later queries.
+type resolvedCandidates struct {
+	network    string
+	candidates []*net.UDPAddr
+}
+
+// lookup returns the kept addresses if they were resolved for o's network,
+// otherwise the addresses address resolves to with o's resolver.  r may be
+// nil.
+func (r *resolvedCandidates) lookup(ctx context.Context, address string, o queryOptions) (candidates []*net.UDPAddr, err error) {
+	if r != nil && r.network == o.network {
+		return append([]*net.UDPAddr(nil), r.candidates...), nil
+	}
+	return resolveCandidates(ctx, o.resolver, address, o.network)
+}
+
+// reachableCandidate returns the first of candidates that c can send to: a
+// socket bound to an IPv4 address cannot reach IPv6 ones.
+func reachableCandidate(candidates []*net.UDPAddr, c net.PacketConn) (candidate *net.UDPAddr, err error) {
+	local, ok := c.LocalAddr().(*net.UDPAddr)
+	if !ok || local.IP.To4() == nil {
+		return candidates[0], nil
+	}
+	for _, candidate = range candidates {
+		if candidate.IP.To4() != nil {
+			return
+		}
+	}
+	return nil, fmt.Errorf("t1net.reachableCandidate: No IPv4 address for %s to send to from %s", candidates[0], local)
+}
+
+// dialFirst sends request to each candidate over its own connection, opened
+// with dial on network, and returns the connection of the first one to
+// reply, along with the candidate, its reply and the round trip time.  The
+// next candidate is tried once the previous one fails or every
+// candidateStagger, Happy Eyeballs style.  Each candidate is given timeout
+// to reply.  The other connections are closed.  If ctx is done first, every
+// connection is closed and ctx.Err() returned.  Packets are recorded in
+// trace, which may be nil.
+func dialFirst(ctx context.Context, dial DialFunc, network string, candidates []*net.UDPAddr, request []byte, timeout time.Duration, trace *QueryTrace) (c net.Conn, remoteAddr *net.UDPAddr, reply []byte, ping time.Duration, err error) {
+	type result struct {
+		c          net.Conn
+		remoteAddr *net.UDPAddr
+		reply      []byte
+		ping       time.Duration
+		err        error
+	}
+	results := make(chan result, len(candidates))
+
+	var (
+		mutex sync.Mutex
+		conns []net.Conn
+		// finished is set once a candidate has won or ctx is done.
+		finished bool
+	)
+	attempt := func(remoteAddr *net.UDPAddr) {
+		r := result{remoteAddr: remoteAddr}
+		defer func() {
+			results <- r
+		}()
+
+		r.c, r.err = dial(ctx, network, remoteAddr.String())
+		if r.err != nil {
+			r.c = nil
+			return
+		}
+		mutex.Lock()
+		if finished {
+			mutex.Unlock()
+			_ = r.c.Close()
+			r.c, r.err = nil, net.ErrClosed
+			return
+		}
+		conns = append(conns, r.c)
+		trace.packet(true, remoteAddr, request)
+		mutex.Unlock()
+
+		sent := time.Now()
+		_, r.err = r.c.Write(request)
+		if r.err == nil {
+			r.err = r.c.SetDeadline(sent.Add(timeout))
+		}
+		readBuffer := make([]byte, 2048)
+		var n int
+		if r.err == nil {
+			n, r.err = r.c.Read(readBuffer)
+		}
+		r.ping = time.Since(sent)
+		r.reply = readBuffer[0:n]
+		mutex.Lock()
+		// The trace belongs to the caller once dialFirst has returned.
+		if r.err == nil && !finished {
+			trace.packet(false, remoteAddr, r.reply)
+		}
+		mutex.Unlock()
+	}
+
+	next, pending := 0, 0
+	start := func() {
+		go attempt(candidates[next])
+		next++
+		pending++
+	}
+	// finish closes every socket but winner and discards the results still
+	// pending.
+	finish := func(winner net.Conn, pending int) {
+		mutex.Lock()
+		finished = true
+		for _, conn := range conns {
+			if conn != winner {
+				_ = conn.Close()
+			}
+		}
+		mutex.Unlock()
+		go func() {
+			for ; pending > 0; pending-- {
+				if late := <-results; late.c != nil {
+					_ = late.c.Close()
+				}
+			}
+		}()
+	}
+	stagger := time.NewTicker(candidateStagger)
+	defer stagger.Stop()
+
+	start()
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err != nil {
+				if r.c != nil {
+					_ = r.c.Close()
+				}
+				err = r.err
+				if next < len(candidates) {
+					start()
+					stagger.Reset(candidateStagger)
+				}
+				continue
+			}
+
+			finish(r.c, pending)
+			return r.c, r.remoteAddr, r.reply, r.ping, nil
+		case <-ctx.Done():
+			finish(nil, pending)
+			return nil, nil, nil, 0, ctx.Err()
+		case <-stagger.C:
+			if next < len(candidates) {
+				start()
+			}
+		}
+	}
+	return
+}
+
+// readCloser is the part of net.Conn and net.PacketConn stopOnDone uses.
+type readCloser interface {
+	SetReadDeadline(t time.Time) error
+	Close() error
+}
+
+// stopOnDone interrupts reads on c once ctx is done: c is closed if owned
+// is set, otherwise its read deadline is moved into the past.  The returned
+// function must be called once c is no longer in use.
+func stopOnDone(ctx context.Context, c readCloser, owned bool) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			if owned {
+				_ = c.Close()
+			} else {
+				_ = c.SetReadDeadline(time.Unix(1, 0))
+			}
+		case <-stopped:
+		}
+	}()
+	return func() {
+		close(stopped)
+	}
+}
+
+// contextTimeout returns timeout, shortened to the time left before ctx's
+// deadline.  It returns ctx.Err() if ctx is already done.
+func contextTimeout(ctx context.Context, timeout time.Duration) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if left := time.Until(deadline); left < timeout {
+			timeout = left
+		}
+	}
+	return timeout, nil
+}
+
+// contextDone returns ctx.Err(), or context.DeadlineExceeded once ctx's
+// deadline has passed even if ctx has not noticed yet: a read timing out at
+// the deadline can return first.
+func contextDone(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
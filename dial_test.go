@@ -0,0 +1,125 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestResolveCandidates(t *testing.T) {
+	candidates, err := resolveCandidates(context.Background(), nil, "127.0.0.1:28001", "udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 1 || candidates[0].String() != "127.0.0.1:28001" {
+		t.Errorf("resolveCandidates(): %v", candidates)
+	}
+
+	_, err = resolveCandidates(context.Background(), nil, "127.0.0.1:tribes", "udp")
+	if err == nil {
+		t.Error("expected an error for an invalid port")
+	}
+	_, err = resolveCandidates(context.Background(), nil, "::1:28001", "udp")
+	if err == nil {
+		t.Error("expected an error for a missing port")
+	}
+
+	candidates, err = resolveCandidates(context.Background(), nil, "[::1]:28001", "udp")
+	if err != nil || len(candidates) != 1 || candidates[0].String() != "[::1]:28001" {
+		t.Errorf("resolveCandidates(): %v, %v", candidates, err)
+	}
+	_, err = resolveCandidates(context.Background(), nil, "[::1]:28001", "udp4")
+	if err == nil {
+		t.Error("expected an error for an IPv6 address on udp4")
+	}
+	_, err = resolveCandidates(context.Background(), nil, "127.0.0.1:28001", "udp6")
+	if err == nil {
+		t.Error("expected an error for an IPv4 address on udp6")
+	}
+	_, err = resolveCandidates(context.Background(), nil, "127.0.0.1:28001", "tcp")
+	if err == nil {
+		t.Error("expected an error for an unknown network")
+	}
+}
+
+func TestDialFirst(t *testing.T) {
+	var candidates []*net.UDPAddr
+	var conns []*net.UDPConn
+	for _, address := range []string{"127.0.0.1:28985", "127.0.0.1:28986"} {
+		s, err := net.ResolveUDPAddr("udp4", address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := net.ListenUDP("udp4", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		candidates = append(candidates, s)
+		conns = append(conns, c)
+	}
+
+	// Only the second candidate answers.
+	go func() {
+		readBuffer := make([]byte, 64)
+		n, addr, err := conns[1].ReadFromUDP(readBuffer)
+		if err != nil {
+			return
+		}
+		_, _ = conns[1].WriteToUDP(append([]byte("re:"), readBuffer[0:n]...), addr)
+	}()
+
+	dial := (&net.Dialer{}).DialContext
+	start := time.Now()
+	c, remoteAddr, reply, ping, err := dialFirst(context.Background(), dial, "udp", candidates, []byte("ping"), time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if elapsed := time.Since(start); elapsed < candidateStagger {
+		t.Errorf("second candidate answered after %s, before the %s stagger", elapsed, candidateStagger)
+	}
+	if !bytes.Equal(reply, []byte("re:ping")) {
+		t.Errorf("reply: %q != re:ping", reply)
+	}
+	if c.RemoteAddr().String() != candidates[1].String() || remoteAddr != candidates[1] {
+		t.Errorf("c.RemoteAddr(): %s, %s != %s", c.RemoteAddr(), remoteAddr, candidates[1])
+	}
+	if ping <= 0 || ping >= candidateStagger {
+		t.Errorf("ping: %s", ping)
+	}
+
+	_, _, _, _, err = dialFirst(context.Background(), dial, "udp", candidates[0:1], []byte("ping"), 50*time.Millisecond, nil)
+	if err == nil {
+		t.Error("expected an error when no candidate answers")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start = time.Now()
+	_, _, _, _, err = dialFirst(ctx, dial, "udp", candidates[0:1], []byte("ping"), 5*time.Second, nil)
+	if err != context.Canceled {
+		t.Errorf("dialFirst() with a cancelled context: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("dialFirst() returned %s after cancellation", elapsed)
+	}
+}
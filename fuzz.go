@@ -0,0 +1,45 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import "errors"
+
+// FuzzGameReply is a go-fuzz entry point for the game server reply parser.
+// It returns 1 for replies that parse and 0 otherwise.
+func FuzzGameReply(data []byte) int {
+	return fuzzResult(NewGameServer("127.0.0.1:28001").LoadReply(data))
+}
+
+// FuzzMasterReply is a go-fuzz entry point for the master server reply
+// parser, given a single reply packet.  It returns 1 for packets that parse
+// and 0 otherwise.
+func FuzzMasterReply(data []byte) int {
+	return fuzzResult(NewMasterServer("127.0.0.1:28000").LoadReply(data))
+}
+
+// fuzzResult converts a parse error into a go-fuzz result.  A recovered
+// parser panic is raised again so the fuzzer records it as a crash.
+func fuzzResult(err error) int {
+	var malformed *MalformedReplyError
+	if errors.As(err, &malformed) {
+		panic(malformed)
+	}
+	if err != nil {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,51 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFuzzEntryPoints(t *testing.T) {
+	status := GameServerStatus{Name: "Fuzzed", Game: "Tribes", Version: "1.11", Players: []Player{{Name: "Alpha"}}}
+	var buffer bytes.Buffer
+	err := status.WriteReply(&buffer, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if FuzzGameReply(buffer.Bytes()) != 1 {
+		t.Error("FuzzGameReply() rejected a valid reply")
+	}
+	if FuzzGameReply(buffer.Bytes()[0:10]) != 0 {
+		t.Error("FuzzGameReply() accepted a truncated reply")
+	}
+
+	packet := []byte{
+		0x10, 0x6, 1, 1, 0x12, 0x34, 0x0, 0x66,
+		6, 'M', 'a', 's', 't', 'e', 'r',
+		4, 'M', 'O', 'T', 'D',
+		0, 1,
+		6, 12, 13, 14, 15, 97, 109,
+	}
+	if FuzzMasterReply(packet) != 1 {
+		t.Error("FuzzMasterReply() rejected a valid reply")
+	}
+	if FuzzMasterReply(packet[0:len(packet)-1]) != 0 {
+		t.Error("FuzzMasterReply() accepted a truncated reply")
+	}
+}
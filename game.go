@@ -18,10 +18,16 @@ package t1net
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"net"
+	"sort"
 	"sync"
 	"time"
 )
@@ -37,8 +43,166 @@ type Player struct {
 	Score string
 	Ping  uint8
 	PL    uint8
+	// Clan is set by the GameServer's ClanResolver, if any.  It is not part
+	// of the reply.
+	Clan string
 }
 
+// GameServerStatus is a copy of the state reported by a game server.
+type GameServerStatus struct {
+	Address           string
+	Ping              time.Duration
+	QueryTime         time.Time
+	Name              string
+	Game              string
+	Version           string
+	Dedicated         bool
+	Password          bool
+	NumPlayers        uint8
+	MaxPlayers        uint8
+	CPUSpeed          uint16
+	Mod               string
+	ServerType        string
+	Mission           string
+	Info              string
+	NumTeams          uint8
+	TeamScoreHeader   string
+	PlayerScoreHeader string
+	Teams             []Team
+	Players           []Player
+	Extension         interface{}
+	Warnings          []string
+}
+
+// Age returns the time elapsed since QueryTime.  It is measured on the
+// monotonic clock while QueryTime still carries a monotonic reading, which
+// is lost when the status is encoded or QueryTime is rounded.  It returns
+// zero if QueryTime is not set.
+func (s *GameServerStatus) Age() (age time.Duration) {
+	if s.QueryTime.IsZero() {
+		return
+	}
+	return time.Since(s.QueryTime)
+}
+
+// WriteReply encodes the status as a GameSpy query reply for the given key.
+// The team and player counts are taken from the Teams and Players slices.
+func (s *GameServerStatus) WriteReply(buffer *bytes.Buffer, key uint16) (err error) {
+	if len(s.Teams) > 255 {
+		return fmt.Errorf("t1net.GameServerStatus.WriteReply: Too many teams: %d > 255", len(s.Teams))
+	}
+	if len(s.Players) > 255 {
+		return fmt.Errorf("t1net.GameServerStatus.WriteReply: Too many players: %d > 255", len(s.Players))
+	}
+
+	buffer.WriteByte(0x63)
+	err = binary.Write(buffer, binary.BigEndian, key)
+	if err != nil {
+		return
+	}
+	buffer.WriteByte(0x62)
+
+	for _, str := range []string{s.Game, s.Version, s.Name} {
+		if err = WritePascalString(buffer, str); err != nil {
+			return
+		}
+	}
+
+	buffer.WriteByte(boolByte(s.Dedicated))
+	buffer.WriteByte(boolByte(s.Password))
+	buffer.WriteByte(byte(len(s.Players)))
+	buffer.WriteByte(s.MaxPlayers)
+	err = binary.Write(buffer, binary.LittleEndian, s.CPUSpeed)
+	if err != nil {
+		return
+	}
+
+	for _, str := range []string{s.Mod, s.ServerType, s.Mission, s.Info} {
+		if err = WritePascalString(buffer, str); err != nil {
+			return
+		}
+	}
+
+	buffer.WriteByte(byte(len(s.Teams)))
+	if err = WritePascalString(buffer, s.TeamScoreHeader); err != nil {
+		return
+	}
+	if err = WritePascalString(buffer, s.PlayerScoreHeader); err != nil {
+		return
+	}
+
+	for _, team := range s.Teams {
+		if err = WritePascalString(buffer, team.Name); err != nil {
+			return
+		}
+		if err = WritePascalString(buffer, team.Score); err != nil {
+			return
+		}
+	}
+
+	for _, player := range s.Players {
+		buffer.WriteByte(player.Ping)
+		buffer.WriteByte(player.PL)
+		buffer.WriteByte(player.Team)
+		if err = WritePascalString(buffer, player.Name); err != nil {
+			return
+		}
+		if err = WritePascalString(buffer, player.Score); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// ExtensionParser decodes mod specific data that follows the standard reply
+// fields.  status holds everything parsed so far.  The returned value is made
+// available through GameServer.Extension.  Any bytes still left in reader
+// afterwards are reported as an error by Query.
+type ExtensionParser func(reader *bytes.Reader, status *GameServerStatus) (extension interface{}, err error)
+
+// Fingerprint identifies a server by the fields that stay the same while it
+// runs (name, game, mod, server type, CPU speed, max players and dedicated
+// flag), so it can be recognised after its address changes.  The mission,
+// players and scores are not included.
+func (s *GameServerStatus) Fingerprint() string {
+	var buffer bytes.Buffer
+	for _, str := range []string{s.Name, s.Game, s.Mod, s.ServerType} {
+		_ = binary.Write(&buffer, binary.LittleEndian, uint32(len(str)))
+		buffer.WriteString(str)
+	}
+	_ = binary.Write(&buffer, binary.LittleEndian, s.CPUSpeed)
+	buffer.WriteByte(s.MaxPlayers)
+	buffer.WriteByte(boolByte(s.Dedicated))
+
+	sum := sha256.Sum256(buffer.Bytes())
+	return hex.EncodeToString(sum[:16])
+}
+
+func copyStatus(status GameServerStatus) GameServerStatus {
+	if status.Teams != nil {
+		teams := make([]Team, len(status.Teams))
+		copy(teams, status.Teams)
+		status.Teams = teams
+	}
+	if status.Players != nil {
+		players := make([]Player, len(status.Players))
+		copy(players, status.Players)
+		status.Players = players
+	}
+	if status.Warnings != nil {
+		warnings := make([]string, len(status.Warnings))
+		copy(warnings, status.Warnings)
+		status.Warnings = warnings
+	}
+	return status
+}
+
+// GameServer queries a game server and holds the results of the last
+// successful query.  It is safe for concurrent use.  The setters can be
+// called while queries are running, as when a daemon reloads its
+// configuration; each query, and each retry of it, uses the configuration in
+// effect when it starts.
 type GameServer struct {
 	mutex             sync.RWMutex
 	address           string
@@ -63,20 +227,61 @@ type GameServer struct {
 	playerScoreHeader string
 	teams             []Team
 	players           []Player
+	stringPool        *StringPool
+	arenaParsing      bool
+	extensionParser   ExtensionParser
+	clanResolver      ClanResolver
+	extension         interface{}
+	limits            Limits
+	trustParsedCounts bool
+	warnings          []string
+	failureTTL        time.Duration
+	failure           time.Time
+	failureErr        error
+	lastSuccess       time.Time
+	minRefresh        time.Duration
+	lastRefresh       time.Time
+	burstProbes       int
+	burstSpacing      time.Duration
+	burstPing         BurstPing
+	quarantine        *Quarantine
+	timeout           time.Duration
+	retries           int
+	backoff           time.Duration
+	tracing           bool
+	trace             *QueryTrace
+	resolved          *resolvedCandidates
 }
 
+// Ping returns the round trip time of the last query, measured on the
+// monotonic clock from sending the request to receiving the reply.  Looking
+// up the server's host is not included.
 func (g *GameServer) Ping() (ping time.Duration) {
 	g.mutex.RLock()
 	defer g.mutex.RUnlock()
 	return g.ping
 }
 
+// QueryTime returns the wall clock time the last query was sent.  Use Age to
+// measure how long ago that was.
 func (g *GameServer) QueryTime() (queryTime time.Time) {
 	g.mutex.RLock()
 	defer g.mutex.RUnlock()
 	return g.queryTime
 }
 
+// Age returns the time elapsed since the last query was sent, measured on
+// the monotonic clock so that wall clock adjustments do not skew it.  It
+// returns zero if the server has not been queried.
+func (g *GameServer) Age() (age time.Duration) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	if g.queryTime.IsZero() {
+		return
+	}
+	return time.Since(g.queryTime)
+}
+
 func (g *GameServer) Name() (name string) {
 	g.mutex.RLock()
 	defer g.mutex.RUnlock()
@@ -183,42 +388,482 @@ func (g *GameServer) Players() (players []Player) {
 	return
 }
 
+// SetStringPool makes future queries share metadata strings (game, version,
+// mod, server type, mission, score headers and team names) through pool.
+// A nil pool disables interning.
+func (g *GameServer) SetStringPool(pool *StringPool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.stringPool = pool
+}
+
+// SetArenaParsing makes future queries slice every string out of a single
+// copy of the reply packet instead of allocating each one separately.  Any
+// string kept from a query keeps that whole packet (at most 2048 bytes) in
+// memory, including strings handed to a StringPool.
+func (g *GameServer) SetArenaParsing(enabled bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.arenaParsing = enabled
+}
+
+func (g *GameServer) Extension() (extension interface{}) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return g.extension
+}
+
+// SetExtensionParser installs parser to decode trailing data in future
+// replies.  Queries call it without holding the server's lock, possibly from
+// several goroutines at once if queries overlap, but LoadReply calls it with
+// the lock held, so it must not call methods on the GameServer.
+func (g *GameServer) SetExtensionParser(parser ExtensionParser) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.extensionParser = parser
+}
+
+func (g *GameServer) Limits() (limits Limits) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return g.limits.withDefaults()
+}
+
+func (g *GameServer) SetLimits(limits Limits) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.limits = limits
+}
+
+// Fingerprint returns the fingerprint of the last status received.
+func (g *GameServer) Fingerprint() string {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	status := g.status()
+	return status.Fingerprint()
+}
+
+// SetTrustParsedCounts makes future queries read players until the end of
+// the reply and use the number actually listed, instead of failing when the
+// player count in the header disagrees with the reply.  Any difference is
+// reported through Warnings.  Nothing is left for an ExtensionParser in
+// this mode, and at most 255 players are read whatever Limits allows, as
+// that is all the count can hold.  The team count is still trusted: teams
+// are listed before players with nothing between them, so a wrong count
+// cannot be told from the reply.
+func (g *GameServer) SetTrustParsedCounts(trust bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.trustParsedCounts = trust
+}
+
+// SetTracing makes future queries record a QueryTrace, returned by Trace.
+func (g *GameServer) SetTracing(enabled bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.tracing = enabled
+}
+
+// Trace returns the trace of the last query made with tracing enabled, or
+// nil if there is none.
+func (g *GameServer) Trace() (trace *QueryTrace) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return g.trace
+}
+
+// Warnings returns the problems the last query worked around.
+func (g *GameServer) Warnings() (warnings []string) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	warnings = make([]string, len(g.warnings))
+	copy(warnings, g.warnings)
+	return
+}
+
+func (g *GameServer) Status() (status GameServerStatus) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return g.status()
+}
+
+func (g *GameServer) status() (status GameServerStatus) {
+	return copyStatus(GameServerStatus{
+		Address:           g.address,
+		Ping:              g.ping,
+		QueryTime:         g.queryTime,
+		Name:              g.name,
+		Game:              g.game,
+		Version:           g.version,
+		Dedicated:         g.dedicated,
+		Password:          g.password,
+		NumPlayers:        g.numPlayers,
+		MaxPlayers:        g.maxPlayers,
+		CPUSpeed:          g.cpuSpeed,
+		Mod:               g.mod,
+		ServerType:        g.serverType,
+		Mission:           g.mission,
+		Info:              g.info,
+		NumTeams:          g.numTeams,
+		TeamScoreHeader:   g.teamScoreHeader,
+		PlayerScoreHeader: g.playerScoreHeader,
+		Teams:             g.teams,
+		Players:           g.players,
+		Extension:         g.extension,
+		Warnings:          g.warnings,
+	})
+}
+
+// scratch returns a GameServer with g's address and the configuration used
+// to parse a reply, for a query to fill in before adopt copies the results
+// back to g.
+func (g *GameServer) scratch() *GameServer {
+	return &GameServer{
+		address:           g.address,
+		stringPool:        g.stringPool,
+		arenaParsing:      g.arenaParsing,
+		extensionParser:   g.extensionParser,
+		clanResolver:      g.clanResolver,
+		limits:            g.limits,
+		trustParsedCounts: g.trustParsedCounts,
+		burstProbes:       g.burstProbes,
+		burstSpacing:      g.burstSpacing,
+		burstPing:         g.burstPing,
+	}
+}
+
+// adopt replaces g's results with those of r, filled in by a query.
+func (g *GameServer) adopt(r *GameServer) {
+	g.ip = r.ip
+	g.port = r.port
+	g.ping = r.ping
+	g.queryTime = r.queryTime
+	g.name = r.name
+	g.game = r.game
+	g.version = r.version
+	g.dedicated = r.dedicated
+	g.password = r.password
+	g.numPlayers = r.numPlayers
+	g.maxPlayers = r.maxPlayers
+	g.cpuSpeed = r.cpuSpeed
+	g.mod = r.mod
+	g.serverType = r.serverType
+	g.mission = r.mission
+	g.info = r.info
+	g.numTeams = r.numTeams
+	g.teamScoreHeader = r.teamScoreHeader
+	g.playerScoreHeader = r.playerScoreHeader
+	g.teams = r.teams
+	g.players = r.players
+	g.extension = r.extension
+	g.warnings = r.warnings
+}
+
+// reset clears the state that is filled in by parsing a reply.
+func (g *GameServer) reset() {
+	g.numTeams = 0
+	g.numPlayers = 0
+	g.maxPlayers = 0
+	g.teams = nil
+	g.players = nil
+	g.extension = nil
+	g.warnings = nil
+}
+
+// CachedFailureError is returned by Query instead of contacting a server
+// whose previous query failed less than its failure TTL ago.
+type CachedFailureError struct {
+	Err error
+	// Age is how long ago the failure happened.
+	Age time.Duration
+}
+
+func (e *CachedFailureError) Error() string {
+	return fmt.Sprintf("t1net.GameServer.Query: Cached failure from %s ago: %v", e.Age, e.Err)
+}
+
+func (e *CachedFailureError) Unwrap() error {
+	return e.Err
+}
+
+// SetFailureTTL makes Query return a CachedFailureError without contacting
+// the server for ttl after a failed query, so repeated lookups of a down
+// server do not each wait out the timeout.  Zero disables the cache.
+func (g *GameServer) SetFailureTTL(ttl time.Duration) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.failureTTL = ttl
+}
+
+// Query sends a query and replaces the results held by g with the reply.
+// The exchange runs without holding g's lock, so the accessors keep
+// returning the previous results until it completes, and a failed query
+// leaves them in place.
 func (g *GameServer) Query(timeout time.Duration, localAddress string) (err error) {
-	if timeout == 0 {
-		timeout = 5 * time.Second
+	return g.QueryContext(context.Background(), timeout, localAddress)
+}
+
+// QueryContext is Query with a context: the query is abandoned with
+// ctx.Err() once ctx is done, and the timeout is shortened to ctx's
+// deadline.  Cancelled queries are not cached as failures.
+func (g *GameServer) QueryContext(ctx context.Context, timeout time.Duration, localAddress string) (err error) {
+	return g.QueryWith(ctx, WithQueryTimeout(timeout), WithLocalAddr(localAddress))
+}
+
+// Resolve looks up the addresses the server's host resolves to, with the
+// resolver and network set by options, and keeps them: later queries on the
+// same network send to them straight away instead of looking the host up
+// again.  Call it again to pick up DNS changes.  If it fails, the addresses
+// kept before are left in place.
+func (g *GameServer) Resolve(ctx context.Context, options ...QueryOption) (err error) {
+	o := newQueryOptions(queryOptions{}, options)
+	candidates, err := resolveCandidates(ctx, o.resolver, g.address, o.network)
+	if err != nil {
+		return
 	}
 
-	var localAddr *net.UDPAddr
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.resolved = &resolvedCandidates{network: o.network, candidates: candidates}
+	return
+}
 
-	if len(localAddress) != 0 {
-		localAddr, err = net.ResolveUDPAddr("udp4", localAddress)
-		if err != nil {
-			return
+// QueryWith is QueryContext configured with options.
+func (g *GameServer) QueryWith(ctx context.Context, options ...QueryOption) (err error) {
+	_, err = g.QueryStatus(ctx, options...)
+	return
+}
+
+// QueryStatus is QueryWith returning a snapshot of the status it received,
+// taken before any other query can replace it.  The snapshot shares nothing
+// with g, so it can be kept, compared with later ones and handed to other
+// goroutines.
+func (g *GameServer) QueryStatus(ctx context.Context, options ...QueryOption) (status GameServerStatus, err error) {
+	g.mutex.RLock()
+	o := newQueryOptions(queryOptions{timeout: g.timeout, retries: g.retries, backoff: g.backoff}, options)
+	ttl, failure, failureErr := g.failureTTL, g.failure, g.failureErr
+	tracing := g.tracing
+	g.mutex.RUnlock()
+	if ttl > 0 && failureErr != nil {
+		if age := time.Since(failure); age < ttl {
+			return status, &CachedFailureError{Err: failureErr, Age: age}
 		}
 	}
 
-	remoteAddr, err := net.ResolveUDPAddr("udp4", g.address)
-	if err != nil {
+	// One trace covers every attempt, so retries show up in it.
+	trace := newTrace(ctx, tracing, o.packetHook, g.address)
+	if trace != nil && trace.recording {
+		defer func() {
+			trace.Done, trace.Err = time.Now(), err
+			g.mutex.Lock()
+			defer g.mutex.Unlock()
+			g.trace = trace
+		}()
+	}
+
+	err = retry(ctx, o.retries, o.backoff, func() (err error) {
+		status, err = g.query(ctx, o, trace)
 		return
+	})
+	if err != nil {
+		if ctxErr := contextDone(ctx); ctxErr != nil {
+			return status, ctxErr
+		}
 	}
 
 	g.mutex.Lock()
 	defer g.mutex.Unlock()
+	g.failure, g.failureErr = time.Time{}, nil
+	if err != nil {
+		g.failure, g.failureErr = time.Now(), err
+	} else {
+		g.lastSuccess = time.Now()
+	}
+	return
+}
 
-	g.ip = remoteAddr.IP
-	g.port = remoteAddr.Port
-	g.numTeams = 0
-	g.numPlayers = 0
-	g.maxPlayers = 0
-	g.teams = nil
-	g.players = nil
+// Online reports whether the last query was answered.  It is false before
+// the first query.  Queries abandoned because their context was done are
+// not counted.
+func (g *GameServer) Online() bool {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return g.failureErr == nil && !g.lastSuccess.IsZero()
+}
+
+// LastError returns the error of the last query, or nil if it succeeded.
+// The results of the last successful query are kept when a query fails, so
+// LastError and LastSuccess tell how stale they are.
+func (g *GameServer) LastError() (err error) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return g.failureErr
+}
+
+// LastSuccess returns when the last successful query completed, or the zero
+// time if none has.
+func (g *GameServer) LastSuccess() (lastSuccess time.Time) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return g.lastSuccess
+}
+
+// SetTimeout sets the timeout Query and Echo use when called with a zero
+// timeout.  The default is 5 seconds.
+func (g *GameServer) SetTimeout(timeout time.Duration) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.timeout = timeout
+}
+
+// SetRetries makes Query send the query again, with a fresh key, up to
+// retries more times while it times out.  It waits backoff before the first
+// retry and doubles the wait before each one after it.  The default is no
+// retries.
+func (g *GameServer) SetRetries(retries int, backoff time.Duration) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.retries = retries
+	g.backoff = backoff
+}
+
+func (g *GameServer) SetMinRefreshInterval(interval time.Duration) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.minRefresh = interval
+}
+
+// Refresh queries the server straight away, ignoring any cached failure.  It
+// returns ErrRefreshLimited if called again within the interval set with
+// SetMinRefreshInterval.
+func (g *GameServer) Refresh(timeout time.Duration, localAddress string) (err error) {
+	g.mutex.Lock()
+	if g.minRefresh > 0 && !g.lastRefresh.IsZero() && time.Since(g.lastRefresh) < g.minRefresh {
+		g.mutex.Unlock()
+		return ErrRefreshLimited
+	}
+	g.lastRefresh = time.Now()
+	g.failure, g.failureErr = time.Time{}, nil
+	g.mutex.Unlock()
+
+	return g.Query(timeout, localAddress)
+}
+
+// BurstPing selects how the round trip times of a burst query are combined
+// into the reported ping.
+type BurstPing int
+
+const (
+	// BurstMinimum reports the fastest round trip.
+	BurstMinimum BurstPing = iota
+	// BurstMedian reports the median round trip.
+	BurstMedian
+)
+
+// SetBurst makes Query send probes queries spaced by spacing and report the
+// minimum or median round trip time as the ping, the way game browsers
+// smooth out latency.  The first reply is parsed.  After the last probe,
+// Query waits up to the timeout for any replies still outstanding; it only
+// fails if none arrive.  A probes value of 1 or less sends a single query.
+// Burst queries only go to the first address the server's host resolves to.
+func (g *GameServer) SetBurst(probes int, spacing time.Duration, ping BurstPing) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.burstProbes = probes
+	g.burstSpacing = spacing
+	g.burstPing = ping
+}
 
-	c, err := net.DialUDP("udp4", localAddr, remoteAddr)
+func (g *GameServer) query(ctx context.Context, o queryOptions, trace *QueryTrace) (status GameServerStatus, err error) {
+	timeout, err := contextTimeout(ctx, o.timeout)
 	if err != nil {
 		return
 	}
 
-	defer c.Close()
+	g.mutex.RLock()
+	r := g.scratch()
+	resolved := g.resolved
+	g.mutex.RUnlock()
+	r.quarantine = o.quarantine
+
+	// The query fills in r without holding g's lock, so readers of g are
+	// not blocked for the length of the exchange.
+	defer func() {
+		if err == nil {
+			g.mutex.Lock()
+			defer g.mutex.Unlock()
+			g.adopt(r)
+			status = g.status()
+		}
+	}()
+
+	if o.simulation != nil {
+		key := uint16(rand.Uint32())
+		var reply []byte
+		r.queryTime = time.Now()
+		reply, r.ping, err = o.simulation.gameReply(ctx, g.address, key, timeout)
+		if err != nil {
+			return
+		}
+		err = r.parseReply(reply, key, true)
+		return
+	}
+
+	dial, err := o.dialer()
+	if err != nil {
+		return
+	}
+
+	candidates, err := resolved.lookup(ctx, g.address, o)
+	if err != nil {
+		return
+	}
+	trace.resolved(candidates)
+
+	// Queries over a shared socket only go to one address, the first the
+	// socket can reach.
+	remoteAddr := candidates[0]
+	switch {
+	case o.client != nil:
+		remoteAddr, err = reachableCandidate(candidates, o.client.conn)
+	case o.conn != nil:
+		remoteAddr, err = reachableCandidate(candidates, o.conn)
+	}
+	if err != nil {
+		return
+	}
+	r.ip = remoteAddr.IP
+	r.port = remoteAddr.Port
+
+	if r.burstProbes > 1 && o.client == nil {
+		c := o.conn
+		if c == nil {
+			var conn net.Conn
+			conn, err = dial(ctx, o.network, remoteAddr.String())
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			c = packetConn{Conn: conn, remoteAddr: remoteAddr}
+		}
+		defer stopOnDone(ctx, c, o.conn == nil)()
+		err = r.queryBurst(c, remoteAddr, timeout, trace)
+		return
+	}
+
+	r.queryTime = time.Now()
+	if o.client != nil {
+		var (
+			reply []byte
+			key   uint16
+		)
+		reply, key, r.ping, err = o.client.query(ctx, remoteAddr, timeout, trace)
+		if err != nil {
+			return
+		}
+		err = r.parseReply(reply, key, true)
+		return
+	}
 
 	key := uint16(rand.Uint32())
 	// 0x62 = GameSpy query request, next two bytes are key
@@ -226,33 +871,150 @@ func (g *GameServer) Query(timeout time.Duration, localAddress string) (err erro
 
 	binary.BigEndian.PutUint16(sendBuffer[1:], key)
 
-	g.queryTime = time.Now()
-	_, err = c.Write(sendBuffer)
-	if err != nil {
+	if o.conn != nil {
+		defer stopOnDone(ctx, o.conn, false)()
+		var reply []byte
+		reply, r.ping, err = exchange(o.conn, remoteAddr, sendBuffer, timeout, trace)
+		if err != nil {
+			return
+		}
+		err = r.parseReply(reply, key, true)
 		return
 	}
 
-	readBuffer := make([]byte, 2048)
-	err = c.SetDeadline(time.Now().Add(timeout))
-	if err != nil {
-		return
-	}
-	n, addr, err := c.ReadFromUDP(readBuffer)
+	c, remoteAddr, reply, ping, err := dialFirst(ctx, dial, o.network, candidates, sendBuffer, timeout, trace)
 	if err != nil {
 		return
 	}
 
-	g.ping = time.Since(g.queryTime)
+	defer c.Close()
 
-	if !addr.IP.Equal(remoteAddr.IP) || addr.Port != remoteAddr.Port {
-		return fmt.Errorf("t1net.GameServer.Query: Reply address mismatch: %s != %s", remoteAddr.String(), addr.String())
+	r.ip = remoteAddr.IP
+	r.port = remoteAddr.Port
+	r.ping = ping
+
+	err = r.parseReply(reply, key, true)
+	return
+}
+
+// queryBurst sends the probes set with SetBurst over c, parses the first
+// reply and sets the ping from the round trip times of all of them.
+// Packets are recorded in trace, which may be nil.
+func (g *GameServer) queryBurst(c net.PacketConn, remoteAddr *net.UDPAddr, timeout time.Duration, trace *QueryTrace) (err error) {
+	sent := make(map[uint16]time.Time, g.burstProbes)
+	answered := make(map[uint16]bool, g.burstProbes)
+	var (
+		pings  []time.Duration
+		parsed bool
+	)
+	// 0x62 = GameSpy query request, next two bytes are key
+	sendBuffer := []byte{0x62, 0x00, 0x00}
+	readBuffer := make([]byte, 2048)
+	for len(sent) < g.burstProbes {
+		key := uint16(rand.Uint32())
+		if _, ok := sent[key]; ok {
+			continue
+		}
+		binary.BigEndian.PutUint16(sendBuffer[1:], key)
+
+		trace.packet(true, remoteAddr, sendBuffer)
+		now := time.Now()
+		if len(sent) == 0 {
+			g.queryTime = now
+		}
+		sent[key] = now
+		err = sendTo(c, remoteAddr, sendBuffer)
+		if err != nil {
+			return
+		}
+
+		last := len(sent) == g.burstProbes
+		deadline := now.Add(g.burstSpacing)
+		if last {
+			deadline = now.Add(timeout)
+		}
+		err = c.SetReadDeadline(deadline)
+		if err != nil {
+			return
+		}
+
+		// Keep reading until the next probe is due, or after the last probe
+		// until every probe has been answered.
+		for !last || len(answered) < len(sent) {
+			n, addr, readErr := c.ReadFrom(readBuffer)
+			if readErr != nil {
+				var netErr net.Error
+				if errors.As(readErr, &netErr) && netErr.Timeout() && (!last || parsed) {
+					break
+				}
+				return readErr
+			}
+			received := time.Now()
+
+			// Packets from other addresses, replies to earlier queries and
+			// repeated replies are skipped.
+			if !sameAddress(addr, remoteAddr) {
+				continue
+			}
+			trace.packet(false, addr, readBuffer[0:n])
+			if n < 3 {
+				continue
+			}
+			replyKey := binary.BigEndian.Uint16(readBuffer[1:3])
+			if _, ok := sent[replyKey]; !ok || answered[replyKey] {
+				continue
+			}
+			answered[replyKey] = true
+			pings = append(pings, received.Sub(sent[replyKey]))
+
+			if !parsed {
+				err = g.parseReply(readBuffer[0:n], replyKey, true)
+				if err != nil {
+					return
+				}
+				parsed = true
+			}
+		}
 	}
 
-	if n < 20 {
-		return fmt.Errorf("t1net.GameServer.Query: Reply packet length too short: %d < 20", n)
+	sort.Slice(pings, func(i, j int) bool { return pings[i] < pings[j] })
+	g.ping = pings[0]
+	if g.burstPing == BurstMedian {
+		g.ping = pings[len(pings)/2]
 	}
+	return
+}
 
-	reader := bytes.NewReader(readBuffer[0:n])
+// parseReply decodes a reply packet into g.  The key is only compared when
+// checkKey is set.  A panic while parsing, including in the ExtensionParser,
+// is returned as a MalformedReplyError.  Packets that fail to parse are
+// stored in g's quarantine, if any.
+func (g *GameServer) parseReply(packet []byte, key uint16, checkKey bool) (err error) {
+	defer func() {
+		g.quarantine.add("game", g.address, packet, err)
+	}()
+	defer recoverMalformed(packet, &err)
+
+	if len(packet) < 20 {
+		return fmt.Errorf("t1net.GameServer.Query: Reply packet length too short: %d < 20", len(packet))
+	}
+
+	reader := bytes.NewReader(packet)
+	readPascal := ReadPascalString
+	if g.arenaParsing {
+		backing := string(packet)
+		readPascal = func(reader *bytes.Reader) (string, error) {
+			return readPascalSubstring(reader, backing)
+		}
+	}
+	limits := g.limits.withDefaults()
+	readString := func(reader *bytes.Reader) (str string, err error) {
+		str, err = readPascal(reader)
+		if err == nil && len(str) > limits.MaxStringLength {
+			err = fmt.Errorf("t1net.GameServer.Query: String length exceeds limit: %d > %d", len(str), limits.MaxStringLength)
+		}
+		return
+	}
 	b, err := reader.ReadByte()
 	if err != nil {
 		return
@@ -267,7 +1029,7 @@ func (g *GameServer) Query(timeout time.Duration, localAddress string) (err erro
 	if err != nil {
 		return
 	}
-	if key != readKey {
+	if checkKey && key != readKey {
 		return fmt.Errorf("t1net.GameServer.Query: Key mismatch: %d : %d", readKey, key)
 	}
 
@@ -280,17 +1042,17 @@ func (g *GameServer) Query(timeout time.Duration, localAddress string) (err erro
 		return fmt.Errorf("t1net.GameServer.Query: Reply byte 3: %#v != 0x62", b)
 	}
 
-	g.game, err = ReadPascalString(reader)
+	g.game, err = readString(reader)
 	if err != nil {
 		return
 	}
 
-	g.version, err = ReadPascalString(reader)
+	g.version, err = readString(reader)
 	if err != nil {
 		return
 	}
 
-	g.name, err = ReadPascalString(reader)
+	g.name, err = readString(reader)
 	if err != nil {
 		return
 	}
@@ -311,6 +1073,9 @@ func (g *GameServer) Query(timeout time.Duration, localAddress string) (err erro
 	if err != nil {
 		return
 	}
+	if int(b) > limits.MaxPlayers {
+		return fmt.Errorf("t1net.GameServer.Query: Player count exceeds limit: %d > %d", b, limits.MaxPlayers)
+	}
 	g.numPlayers = b
 
 	b, err = reader.ReadByte()
@@ -324,22 +1089,22 @@ func (g *GameServer) Query(timeout time.Duration, localAddress string) (err erro
 		return
 	}
 
-	g.mod, err = ReadPascalString(reader)
+	g.mod, err = readString(reader)
 	if err != nil {
 		return
 	}
 
-	g.serverType, err = ReadPascalString(reader)
+	g.serverType, err = readString(reader)
 	if err != nil {
 		return
 	}
 
-	g.mission, err = ReadPascalString(reader)
+	g.mission, err = readString(reader)
 	if err != nil {
 		return
 	}
 
-	g.info, err = ReadPascalString(reader)
+	g.info, err = readString(reader)
 	if err != nil {
 		return
 	}
@@ -348,36 +1113,62 @@ func (g *GameServer) Query(timeout time.Duration, localAddress string) (err erro
 	if err != nil {
 		return
 	}
+	if int(b) > limits.MaxTeams {
+		return fmt.Errorf("t1net.GameServer.Query: Team count exceeds limit: %d > %d", b, limits.MaxTeams)
+	}
 	g.numTeams = b
 
-	g.teamScoreHeader, err = ReadPascalString(reader)
+	g.teamScoreHeader, err = readString(reader)
 	if err != nil {
 		return
 	}
 
-	g.playerScoreHeader, err = ReadPascalString(reader)
+	g.playerScoreHeader, err = readString(reader)
 	if err != nil {
 		return
 	}
 
+	g.game = g.stringPool.Intern(g.game)
+	g.version = g.stringPool.Intern(g.version)
+	g.mod = g.stringPool.Intern(g.mod)
+	g.serverType = g.stringPool.Intern(g.serverType)
+	g.mission = g.stringPool.Intern(g.mission)
+	g.teamScoreHeader = g.stringPool.Intern(g.teamScoreHeader)
+	g.playerScoreHeader = g.stringPool.Intern(g.playerScoreHeader)
+
 	var teamName, teamScore string
 	for i := uint8(0); i < g.numTeams; i++ {
-		teamName, err = ReadPascalString(reader)
+		teamName, err = readString(reader)
 		if err != nil {
 			return
 		}
 
-		teamScore, err = ReadPascalString(reader)
+		teamScore, err = readString(reader)
 		if err != nil {
 			return
 		}
 
-		g.teams = append(g.teams, Team{Name: teamName, Score: teamScore})
+		g.teams = append(g.teams, Team{Name: g.stringPool.Intern(teamName), Score: teamScore})
 	}
 
 	var ping, pl, team byte
 	var playerName, playerScore string
-	for i := uint8(0); i < g.numPlayers; i++ {
+	morePlayers := func(i int) bool {
+		if g.trustParsedCounts {
+			return reader.Len() > 0
+		}
+		return i < int(g.numPlayers)
+	}
+	maxPlayers := limits.MaxPlayers
+	if g.trustParsedCounts && maxPlayers > math.MaxUint8 {
+		// The number listed replaces the one byte count in the header.
+		maxPlayers = math.MaxUint8
+	}
+	for i := 0; morePlayers(i); i++ {
+		if i >= maxPlayers {
+			return fmt.Errorf("t1net.GameServer.Query: Player count exceeds limit: %d > %d", i+1, maxPlayers)
+		}
+
 		ping, err = reader.ReadByte()
 		if err != nil {
 			return
@@ -393,17 +1184,34 @@ func (g *GameServer) Query(timeout time.Duration, localAddress string) (err erro
 			return
 		}
 
-		playerName, err = ReadPascalString(reader)
+		playerName, err = readString(reader)
 		if err != nil {
 			return
 		}
 
-		playerScore, err = ReadPascalString(reader)
+		playerScore, err = readString(reader)
 		if err != nil {
 			return
 		}
 
-		g.players = append(g.players, Player{Ping: ping, PL: pl, Team: team, Name: playerName, Score: playerScore})
+		player := Player{Ping: ping, PL: pl, Team: team, Name: playerName, Score: playerScore}
+		if g.clanResolver != nil {
+			player.Clan = g.clanResolver(playerName)
+		}
+		g.players = append(g.players, player)
+	}
+
+	if g.trustParsedCounts && len(g.players) != int(g.numPlayers) {
+		g.warnings = append(g.warnings, fmt.Sprintf("player count %d replaced by %d players listed", g.numPlayers, len(g.players)))
+		g.numPlayers = uint8(len(g.players))
+	}
+
+	if g.extensionParser != nil {
+		status := g.status()
+		g.extension, err = g.extensionParser(reader, &status)
+		if err != nil {
+			return
+		}
 	}
 
 	if reader.Len() != 0 {
@@ -413,6 +1221,86 @@ func (g *GameServer) Query(timeout time.Duration, localAddress string) (err erro
 	return
 }
 
+// LoadReply decodes a recorded reply packet as if it had been received by
+// Query, replacing the current state.  The ping and query time are left
+// unchanged and the key is not checked.
+func (g *GameServer) LoadReply(packet []byte) (err error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.reset()
+	return g.parseReply(packet, 0, false)
+}
+
+// Echo measures the round trip time to the server with a GameSpy \echo\
+// exchange, which is much cheaper than a full Query.  Not every server
+// answers it.  The server's state is left untouched.
+func (g *GameServer) Echo(timeout time.Duration, localAddress string) (ping time.Duration, err error) {
+	return g.EchoWith(context.Background(), WithQueryTimeout(timeout), WithLocalAddr(localAddress))
+}
+
+// EchoWith is Echo configured with options, which are honoured as by
+// QueryWith, except that retries are not made and WithClient is ignored:
+// the echo goes over the socket set with WithConn or its own.  Simulations
+// do not answer echoes.
+func (g *GameServer) EchoWith(ctx context.Context, options ...QueryOption) (ping time.Duration, err error) {
+	g.mutex.RLock()
+	o := newQueryOptions(queryOptions{timeout: g.timeout}, options)
+	resolved := g.resolved
+	g.mutex.RUnlock()
+
+	if o.simulation != nil {
+		return 0, errors.New("t1net.GameServer.Echo: Simulations do not answer echoes")
+	}
+	timeout, err := contextTimeout(ctx, o.timeout)
+	if err != nil {
+		return
+	}
+	candidates, err := resolved.lookup(ctx, g.address, o)
+	if err != nil {
+		return
+	}
+	trace := newTrace(ctx, false, o.packetHook, g.address)
+
+	request := []byte(fmt.Sprintf("\\echo\\%08x", rand.Uint32()))
+	var reply []byte
+	if o.conn != nil {
+		var remoteAddr *net.UDPAddr
+		remoteAddr, err = reachableCandidate(candidates, o.conn)
+		if err != nil {
+			return
+		}
+		defer stopOnDone(ctx, o.conn, false)()
+		reply, ping, err = exchange(o.conn, remoteAddr, request, timeout, trace)
+	} else {
+		var (
+			dial DialFunc
+			c    net.Conn
+		)
+		dial, err = o.dialer()
+		if err != nil {
+			return
+		}
+		c, _, reply, ping, err = dialFirst(ctx, dial, o.network, candidates, request, timeout, trace)
+		if err == nil {
+			_ = c.Close()
+		}
+	}
+	if err != nil {
+		if ctxErr := contextDone(ctx); ctxErr != nil {
+			err = ctxErr
+		}
+		return 0, err
+	}
+
+	if !bytes.HasPrefix(reply, request) {
+		return 0, fmt.Errorf("t1net.GameServer.Echo: Reply does not match request: %q != %q", reply, request)
+	}
+	return
+}
+
+// NewGameServer returns a GameServer for address, normalized with
+// NormalizeAddress and DefaultGamePort.
 func NewGameServer(address string) *GameServer {
-	return &GameServer{address: address}
+	return &GameServer{address: normalizeOrKeep(address, DefaultGamePort)}
 }
@@ -18,6 +18,10 @@ package t1net
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"math/rand"
 	"net"
 	"testing"
@@ -194,4 +198,348 @@ func TestGameServer(t *testing.T) {
 			t.Errorf("game.Players(): Player name mismatch: %s != %s", player.Name, expectedPlayerNames[i])
 		}
 	}
+
+	if age := game.Age(); age < game.Ping() {
+		t.Errorf("game.Age(): %s < game.Ping(): %s", age, game.Ping())
+	}
+}
+
+func TestGameServerStatusAge(t *testing.T) {
+	status := GameServerStatus{QueryTime: time.Now().Add(-time.Minute)}
+	if age := status.Age(); age < time.Minute {
+		t.Errorf("status.Age(): %s < 1m", age)
+	}
+
+	status = GameServerStatus{}
+	if age := status.Age(); age != 0 {
+		t.Errorf("status.Age(): %s != 0", age)
+	}
+	if age := NewGameServer("127.0.0.1:28001").Age(); age != 0 {
+		t.Errorf("NewGameServer().Age(): %s != 0", age)
+	}
+}
+
+// replyOnce answers a single query sent to address with the bytes returned
+// by reply, which is given the request key.
+func replyOnce(t *testing.T, address string, reply func(key uint16) []byte) (closer func()) {
+	s, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := net.ListenUDP("udp", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		readBuffer := make([]byte, 64)
+		err := c.SetDeadline(time.Now().Add(1 * time.Second))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		n, addr, err := c.ReadFromUDP(readBuffer)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if n != 3 || readBuffer[0] != 0x62 {
+			t.Errorf("Unexpected query: %v", readBuffer[0:n])
+			return
+		}
+		_, err = c.WriteToUDP(reply(binary.BigEndian.Uint16(readBuffer[1:3])), addr)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	return func() {
+		_ = c.Close()
+	}
+}
+
+func TestGameServerExtensionParser(t *testing.T) {
+	status := GameServerStatus{
+		Name:    "Extended",
+		Mod:     "extmod",
+		Players: []Player{{Name: "Alpha"}},
+	}
+	closer := replyOnce(t, "127.0.0.1:28996", func(key uint16) []byte {
+		var buffer bytes.Buffer
+		err := status.WriteReply(&buffer, key)
+		if err != nil {
+			t.Error(err)
+		}
+		buffer.Write([]byte{3, 'e', 'x', 't'})
+		return buffer.Bytes()
+	})
+	defer closer()
+
+	game := NewGameServer("127.0.0.1:28996")
+	game.SetExtensionParser(func(reader *bytes.Reader, status *GameServerStatus) (interface{}, error) {
+		if status.Mod != "extmod" || len(status.Players) != 1 {
+			return nil, fmt.Errorf("unexpected partial status: %v", status)
+		}
+		return ReadPascalString(reader)
+	})
+	err := game.Query(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Extension() != "ext" {
+		t.Errorf("game.Extension(): %v != ext", game.Extension())
+	}
+	if game.Status().Extension != "ext" {
+		t.Errorf("game.Status().Extension: %v != ext", game.Status().Extension)
+	}
+}
+
+func TestGameServerStatusFingerprint(t *testing.T) {
+	a := GameServerStatus{Address: "1.2.3.4:28001", Name: "Server", Mod: "base", CPUSpeed: 3500, Mission: "Raindance"}
+	b := a
+	b.Address = "5.6.7.8:28002"
+	b.Mission = "Broadside"
+	b.Players = []Player{{Name: "Alpha"}}
+	if a.Fingerprint() != b.Fingerprint() {
+		t.Errorf("fingerprint changed with address or mission: %s != %s", a.Fingerprint(), b.Fingerprint())
+	}
+
+	b.Name = "Other Server"
+	if a.Fingerprint() == b.Fingerprint() {
+		t.Error("fingerprint did not change with the server name")
+	}
+
+	// Field boundaries are part of the fingerprint.
+	c := GameServerStatus{Name: "ab", Game: "c"}
+	d := GameServerStatus{Name: "a", Game: "bc"}
+	if c.Fingerprint() == d.Fingerprint() {
+		t.Error("fingerprint ignores field boundaries")
+	}
+}
+
+func TestGameServerTrustParsedCounts(t *testing.T) {
+	status := GameServerStatus{
+		Name:       "X",
+		MaxPlayers: 16,
+		Players:    []Player{{Name: "Alpha"}, {Name: "Beta"}},
+	}
+	reply := func(key uint16) []byte {
+		var buffer bytes.Buffer
+		err := status.WriteReply(&buffer, key)
+		if err != nil {
+			t.Error(err)
+		}
+		packet := buffer.Bytes()
+		// Claim one player while listing two.
+		packet[10] = 1
+		return packet
+	}
+
+	closer := replyOnce(t, "127.0.0.1:28994", reply)
+	game := NewGameServer("127.0.0.1:28994")
+	err := game.Query(0, "")
+	closer()
+	if err == nil {
+		t.Fatal("expected left over bytes without SetTrustParsedCounts")
+	}
+
+	closer = replyOnce(t, "127.0.0.1:28994", reply)
+	defer closer()
+	game.SetTrustParsedCounts(true)
+	err = game.Query(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.NumPlayers() != 2 || len(game.Players()) != 2 {
+		t.Errorf("game.NumPlayers(): %d, len(game.Players()): %d, expected 2", game.NumPlayers(), len(game.Players()))
+	}
+	if len(game.Warnings()) != 1 {
+		t.Errorf("game.Warnings(): %v", game.Warnings())
+	}
+
+	// More players than the count can hold are rejected, even if Limits
+	// allows them.
+	many := GameServerStatus{Name: "Crowded"}
+	for i := 0; i < 255; i++ {
+		many.Players = append(many.Players, Player{Name: "P"})
+	}
+	var buffer bytes.Buffer
+	if err = many.WriteReply(&buffer, 0); err != nil {
+		t.Fatal(err)
+	}
+	buffer.Write([]byte{0, 0, 0, 1, 'P', 0})
+	crowded := NewGameServer("127.0.0.1:28001")
+	crowded.SetTrustParsedCounts(true)
+	crowded.SetLimits(Limits{MaxPlayers: 300})
+	if err = crowded.LoadReply(buffer.Bytes()); err == nil {
+		t.Errorf("LoadReply(): expected an error for 256 players, got %d", crowded.NumPlayers())
+	}
+}
+
+func TestGameServerLoadReply(t *testing.T) {
+	status := GameServerStatus{
+		Name:    "Recorded",
+		Mission: "Raindance",
+		Teams:   []Team{{Name: "Blood Eagle", Score: "1"}},
+		Players: []Player{{Name: "Alpha", Score: "3"}},
+	}
+	var buffer bytes.Buffer
+	err := status.WriteReply(&buffer, 0x1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	game := NewGameServer("127.0.0.1:28001")
+	err = game.LoadReply(buffer.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Name() != "Recorded" || game.Mission() != "Raindance" {
+		t.Errorf("game.Name(): %s, game.Mission(): %s", game.Name(), game.Mission())
+	}
+	if len(game.Players()) != 1 || game.Players()[0].Score != "3" {
+		t.Errorf("game.Players(): %v", game.Players())
+	}
+
+	err = game.LoadReply(buffer.Bytes()[0:10])
+	if err == nil {
+		t.Fatal("expected an error for a truncated reply")
+	}
+}
+
+func TestGameServerFailureTTL(t *testing.T) {
+	// Nothing listens on this port, so the first query times out.
+	game := NewGameServer("127.0.0.1:28992")
+	game.SetFailureTTL(time.Minute)
+	err := game.Query(100*time.Millisecond, "")
+	if err == nil {
+		t.Fatal("expected the first query to fail")
+	}
+
+	start := time.Now()
+	err = game.Query(100*time.Millisecond, "")
+	var cached *CachedFailureError
+	if !errors.As(err, &cached) {
+		t.Fatalf("expected a CachedFailureError, got %v", err)
+	}
+	if time.Since(start) >= 100*time.Millisecond {
+		t.Error("cached failure waited for the timeout")
+	}
+
+	game.SetFailureTTL(0)
+	err = game.Query(100*time.Millisecond, "")
+	if errors.As(err, &cached) {
+		t.Error("failure was cached with the TTL disabled")
+	}
+}
+
+func TestGameServerRefresh(t *testing.T) {
+	game := NewGameServer("127.0.0.1:28992")
+	game.SetFailureTTL(time.Minute)
+	game.SetMinRefreshInterval(time.Minute)
+
+	err := game.Query(100*time.Millisecond, "")
+	if err == nil {
+		t.Fatal("expected the first query to fail")
+	}
+
+	// Refresh skips the cached failure and contacts the server again.
+	err = game.Refresh(100*time.Millisecond, "")
+	var cached *CachedFailureError
+	if err == nil || errors.As(err, &cached) {
+		t.Fatalf("expected a fresh failure, got %v", err)
+	}
+
+	err = game.Refresh(100*time.Millisecond, "")
+	if err != ErrRefreshLimited {
+		t.Fatalf("expected ErrRefreshLimited, got %v", err)
+	}
+}
+
+func TestGameServerBurst(t *testing.T) {
+	responder := NewQueryResponder("127.0.0.1:28987")
+	responder.SetStatus(GameServerStatus{Name: "Burst Server", Game: "Tribes", Version: "1.11"})
+	probes := make(chan AccessRecord, 16)
+	responder.SetAccessLog(func(record AccessRecord) {
+		probes <- record
+	})
+	stop := serveResponder(t, responder, "127.0.0.1:28987")
+
+	game := NewGameServer("127.0.0.1:28987")
+	game.SetBurst(3, 20*time.Millisecond, BurstMedian)
+	start := time.Now()
+	err := game.Query(time.Second, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("burst finished in %s, expected at least 40ms", elapsed)
+	}
+	if game.Name() != "Burst Server" {
+		t.Errorf("game.Name(): %s != Burst Server", game.Name())
+	}
+	if game.Ping() <= 0 || game.Ping() > time.Second {
+		t.Errorf("game.Ping(): %s", game.Ping())
+	}
+
+	stop()
+	if len(probes) != 3 {
+		t.Errorf("responder saw %d probes, expected 3", len(probes))
+	}
+}
+
+func TestGameServerMalformedReply(t *testing.T) {
+	status := GameServerStatus{Name: "Hostile", Mod: "panicmod"}
+	var buffer bytes.Buffer
+	err := status.WriteReply(&buffer, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buffer.Write([]byte{1, 2, 3})
+
+	game := NewGameServer("127.0.0.1:28001")
+	game.SetExtensionParser(func(reader *bytes.Reader, status *GameServerStatus) (extension interface{}, err error) {
+		var values []int
+		return values[reader.Len()], nil
+	})
+	err = game.LoadReply(buffer.Bytes())
+	var malformed *MalformedReplyError
+	if !errors.As(err, &malformed) {
+		t.Fatalf("game.LoadReply(): %v is not a MalformedReplyError", err)
+	}
+	if !bytes.Equal(malformed.Packet, buffer.Bytes()) || malformed.Panic == nil {
+		t.Errorf("malformed: %+v", malformed)
+	}
+}
+
+func TestGameServerQueryContext(t *testing.T) {
+	s, err := net.ResolveUDPAddr("udp4", "127.0.0.1:28983")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := net.ListenUDP("udp4", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	game := NewGameServer("127.0.0.1:28983")
+	game.SetFailureTTL(time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = game.QueryContext(ctx, 5*time.Second, "")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("game.QueryContext(): %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("game.QueryContext() returned after %s", elapsed)
+	}
+
+	// The cancelled query was not cached as a failure.
+	err = game.QueryContext(ctx, 0, "")
+	var cached *CachedFailureError
+	if errors.As(err, &cached) {
+		t.Errorf("game.QueryContext(): %v", err)
+	}
 }
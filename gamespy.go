@@ -0,0 +1,121 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"strconv"
+	"strings"
+)
+
+// gameSpyMaxPacket is the size at which GameSpy text replies are split.
+const gameSpyMaxPacket = 1400
+
+// gameSpyValue makes str safe to use as a GameSpy text protocol value, which
+// cannot contain backslashes.
+func gameSpyValue(str string) string {
+	return strings.ReplaceAll(str, "\\", "/")
+}
+
+// gameSpyPairs returns the key/value pairs answering a GameSpy text protocol
+// query (basic, info, rules, players or status) for the status.  port is the
+// game port reported as hostport.  It returns nil for unknown queries.
+func (s *GameServerStatus) gameSpyPairs(query string, port int) (pairs []string) {
+	basic := func() {
+		pairs = append(pairs,
+			"gamename", "tribes",
+			"gamever", s.Version,
+		)
+	}
+	info := func() {
+		pairs = append(pairs,
+			"hostname", s.Name,
+			"hostport", strconv.Itoa(port),
+			"mapname", s.Mission,
+			"gametype", s.ServerType,
+			"numplayers", strconv.Itoa(len(s.Players)),
+			"maxplayers", strconv.Itoa(int(s.MaxPlayers)),
+			"gamemode", "openplaying",
+		)
+	}
+	rules := func() {
+		pairs = append(pairs,
+			"mod", s.Mod,
+			"info", s.Info,
+			"dedicated", strconv.Itoa(int(boolByte(s.Dedicated))),
+			"password", strconv.Itoa(int(boolByte(s.Password))),
+			"cpu", strconv.Itoa(int(s.CPUSpeed)),
+		)
+	}
+	players := func() {
+		for i, player := range s.Players {
+			n := strconv.Itoa(i)
+			team := ""
+			if int(player.Team) < len(s.Teams) {
+				team = s.Teams[player.Team].Name
+			}
+			pairs = append(pairs,
+				"player_"+n, player.Name,
+				"score_"+n, player.Score,
+				"ping_"+n, strconv.Itoa(int(player.Ping)),
+				"team_"+n, team,
+			)
+		}
+	}
+
+	switch query {
+	case "basic":
+		basic()
+	case "info":
+		info()
+	case "rules":
+		rules()
+	case "players":
+		players()
+	case "status":
+		basic()
+		info()
+		rules()
+		players()
+	}
+	return
+}
+
+// gameSpyPackets encodes pairs as GameSpy text replies no larger than
+// gameSpyMaxPacket, numbering them with queryID.
+func gameSpyPackets(pairs []string, queryID int) (packets [][]byte) {
+	var builder strings.Builder
+	id := strconv.Itoa(queryID)
+	flush := func(final bool) {
+		if final {
+			builder.WriteString("\\final\\")
+		}
+		builder.WriteString("\\queryid\\" + id + "." + strconv.Itoa(len(packets)+1))
+		packets = append(packets, []byte(builder.String()))
+		builder.Reset()
+	}
+
+	for i := 0; i+1 < len(pairs); i += 2 {
+		pair := "\\" + gameSpyValue(pairs[i]) + "\\" + gameSpyValue(pairs[i+1])
+		// Leave room for the trailing final and queryid keys.
+		if builder.Len() > 0 && builder.Len()+len(pair)+32 > gameSpyMaxPacket {
+			flush(false)
+		}
+		builder.WriteString(pair)
+	}
+	flush(true)
+	return
+}
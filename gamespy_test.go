@@ -0,0 +1,76 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestGameSpyPairs(t *testing.T) {
+	status := GameServerStatus{
+		Name:    "Back\\slash",
+		Version: "1.30",
+		Teams:   []Team{{Name: "Blood Eagle"}},
+		Players: []Player{{Name: "Alpha", Team: 0, Score: "5", Ping: 40}, {Name: "Beta", Team: 255}},
+	}
+
+	packets := gameSpyPackets(status.gameSpyPairs("status", 28001), 7)
+	if len(packets) != 1 {
+		t.Fatalf("len(packets): %d != 1", len(packets))
+	}
+	for _, expected := range []string{
+		"\\gamename\\tribes\\gamever\\1.30",
+		"\\hostname\\Back/slash\\hostport\\28001",
+		"\\numplayers\\2",
+		"\\player_0\\Alpha\\score_0\\5\\ping_0\\40\\team_0\\Blood Eagle",
+		"\\team_1\\\\final\\\\queryid\\7.1",
+	} {
+		if !bytes.Contains(packets[0], []byte(expected)) {
+			t.Errorf("%q does not contain %q", packets[0], expected)
+		}
+	}
+
+	if status.gameSpyPairs("unknown", 28001) != nil {
+		t.Error("unknown query returned pairs")
+	}
+}
+
+func TestGameSpyPacketsSplit(t *testing.T) {
+	var pairs []string
+	for i := 0; i < 200; i++ {
+		pairs = append(pairs, fmt.Sprintf("player_%d", i), "A fairly long player name")
+	}
+
+	packets := gameSpyPackets(pairs, 1)
+	if len(packets) < 2 {
+		t.Fatalf("len(packets): %d < 2", len(packets))
+	}
+	for i, packet := range packets {
+		if len(packet) > gameSpyMaxPacket {
+			t.Errorf("packet %d: length %d > %d", i, len(packet), gameSpyMaxPacket)
+		}
+		final := bytes.Contains(packet, []byte("\\final\\"))
+		if final != (i == len(packets)-1) {
+			t.Errorf("packet %d: final %t", i, final)
+		}
+		if !bytes.HasSuffix(packet, []byte(fmt.Sprintf("\\queryid\\1.%d", i+1))) {
+			t.Errorf("packet %d: missing queryid: %q", i, packet)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import "time"
+
+// HealthState is where a server stands in the health state machine driven by
+// Health.Record.
+type HealthState string
+
+const (
+	// HealthNew means the server has not yet answered or failed often enough
+	// to be judged.
+	HealthNew HealthState = "new"
+	// HealthHealthy means the server answers reliably.
+	HealthHealthy HealthState = "healthy"
+	// HealthFlapping means the server keeps alternating between answering
+	// and not.
+	HealthFlapping HealthState = "flapping"
+	// HealthDown means the server has stopped answering.
+	HealthDown HealthState = "down"
+	// HealthRetired means the server has been down long enough to be
+	// dropped from listings.
+	HealthRetired HealthState = "retired"
+)
+
+// HealthThresholds sets the hysteresis of the health state machine, so that
+// one dropped reply does not move a server between states.  A zero field
+// uses the value from DefaultHealthThresholds.
+type HealthThresholds struct {
+	// UpAfter is how many consecutive answers make a server healthy.
+	UpAfter int
+	// DownAfter is how many consecutive failures make a server down.
+	DownAfter int
+	// FlapWindow is how many of the latest outcomes are checked for
+	// flapping, at most 64.
+	FlapWindow int
+	// FlapChanges is how many changes between answering and failing within
+	// FlapWindow make a server flapping.
+	FlapChanges int
+	// RetireAfter is how long a server stays down before it is retired.
+	RetireAfter time.Duration
+	// ReliabilityWeight is the weight of the latest outcome in
+	// Health.Reliability, between 0 and 1.  Higher values forget the past
+	// faster.
+	ReliabilityWeight float64
+}
+
+var DefaultHealthThresholds = HealthThresholds{
+	UpAfter:           2,
+	DownAfter:         3,
+	FlapWindow:        10,
+	FlapChanges:       4,
+	RetireAfter:       24 * time.Hour,
+	ReliabilityWeight: 0.1,
+}
+
+// withDefaults returns t with every zero field replaced by its default.
+func (t HealthThresholds) withDefaults() HealthThresholds {
+	if t.UpAfter == 0 {
+		t.UpAfter = DefaultHealthThresholds.UpAfter
+	}
+	if t.DownAfter == 0 {
+		t.DownAfter = DefaultHealthThresholds.DownAfter
+	}
+	if t.FlapWindow == 0 {
+		t.FlapWindow = DefaultHealthThresholds.FlapWindow
+	}
+	if t.FlapWindow > 64 {
+		t.FlapWindow = 64
+	}
+	if t.FlapChanges == 0 {
+		t.FlapChanges = DefaultHealthThresholds.FlapChanges
+	}
+	if t.RetireAfter == 0 {
+		t.RetireAfter = DefaultHealthThresholds.RetireAfter
+	}
+	if t.ReliabilityWeight <= 0 || t.ReliabilityWeight > 1 {
+		t.ReliabilityWeight = DefaultHealthThresholds.ReliabilityWeight
+	}
+	return t
+}
+
+// Health tracks a server through the health states.  The zero value is a
+// HealthNew server.  It is a plain value: copies are snapshots.
+type Health struct {
+	State HealthState
+	// Since is when State was entered.
+	Since time.Time
+	// Successes and Failures count the consecutive outcomes of the same kind
+	// up to the latest; one of them is always zero.
+	Successes   int
+	Failures    int
+	LastSuccess time.Time
+	LastFailure time.Time
+	// Reliability is an exponentially weighted moving average of the
+	// outcomes, 1 for an answer and 0 for a failure, so recent outcomes
+	// count most.  It starts at the first outcome.  Sort by it to rank
+	// stable servers above flaky ones.
+	Reliability float64
+	// outcomes holds the latest outcomes, a set bit for an answer, newest in
+	// bit 0, and recorded how many of its bits are valid.
+	outcomes uint64
+	recorded int
+}
+
+// changes returns how many times the outcome changed within the latest
+// window outcomes.
+func (h *Health) changes(window int) (changes int) {
+	n := h.recorded
+	if n > window {
+		n = window
+	}
+	for i := 1; i < n; i++ {
+		if (h.outcomes>>i)&1 != (h.outcomes>>(i-1))&1 {
+			changes++
+		}
+	}
+	return
+}
+
+// Record adds the outcome of a query made at now and moves the server to the
+// state thresholds call for.  A server is flapping while its latest
+// FlapWindow outcomes change FlapChanges times or more, and stays flapping
+// afterwards until it answers UpAfter times or fails DownAfter times in a
+// row.  A down server is retired by the first failure RetireAfter after it
+// went down.
+func (h *Health) Record(answered bool, now time.Time, thresholds HealthThresholds) {
+	thresholds = thresholds.withDefaults()
+	if len(h.State) == 0 {
+		h.State, h.Since = HealthNew, now
+	}
+
+	h.outcomes <<= 1
+	if answered {
+		h.outcomes |= 1
+		h.Successes, h.Failures = h.Successes+1, 0
+		h.LastSuccess = now
+	} else {
+		h.Successes, h.Failures = 0, h.Failures+1
+		h.LastFailure = now
+	}
+	outcome := 0.0
+	if answered {
+		outcome = 1
+	}
+	if h.recorded == 0 {
+		h.Reliability = outcome
+	} else {
+		h.Reliability += thresholds.ReliabilityWeight * (outcome - h.Reliability)
+	}
+	if h.recorded < 64 {
+		h.recorded++
+	}
+
+	state := h.State
+	switch {
+	case h.changes(thresholds.FlapWindow) >= thresholds.FlapChanges:
+		state = HealthFlapping
+	case h.Successes >= thresholds.UpAfter:
+		state = HealthHealthy
+	case h.Failures >= thresholds.DownAfter && state != HealthRetired:
+		state = HealthDown
+		if h.State == HealthDown && now.Sub(h.Since) >= thresholds.RetireAfter {
+			state = HealthRetired
+		}
+	}
+	if state != h.State {
+		h.State, h.Since = state, now
+	}
+}
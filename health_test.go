@@ -0,0 +1,82 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHealthRecord(t *testing.T) {
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	var h Health
+	record := func(answered bool, expected HealthState) {
+		t.Helper()
+		now = now.Add(time.Minute)
+		h.Record(answered, now, HealthThresholds{})
+		if h.State != expected {
+			t.Errorf("h.State: %s != %s", h.State, expected)
+		}
+	}
+
+	record(true, HealthNew)
+	record(true, HealthHealthy)
+	record(false, HealthHealthy)
+	record(false, HealthHealthy)
+	record(false, HealthDown)
+	if !h.Since.Equal(now) || h.Failures != 3 {
+		t.Errorf("h.Since: %s, h.Failures: %d", h.Since, h.Failures)
+	}
+	now = now.Add(DefaultHealthThresholds.RetireAfter)
+	record(false, HealthRetired)
+	record(true, HealthRetired)
+	record(true, HealthHealthy)
+
+	h = Health{}
+	record(true, HealthNew)
+	record(false, HealthNew)
+	record(true, HealthNew)
+	record(false, HealthNew)
+	record(true, HealthFlapping)
+	// The flaps stay within the window for five more answers.
+	for i := 0; i < 5; i++ {
+		record(true, HealthFlapping)
+	}
+	record(true, HealthHealthy)
+}
+
+func TestHealthReliability(t *testing.T) {
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	thresholds := HealthThresholds{ReliabilityWeight: 0.5}
+	var h Health
+	for i, expected := range []float64{1, 0.5, 0.25, 0.625} {
+		h.Record(i%3 == 0, now, thresholds)
+		if h.Reliability != expected {
+			t.Errorf("h.Reliability after %d outcomes: %v != %v", i+1, h.Reliability, expected)
+		}
+	}
+
+	entries := []BrowserEntry{
+		{Status: GameServerStatus{Name: "Flaky"}, Health: Health{Reliability: 0.3}},
+		{Status: GameServerStatus{Name: "Stable"}, Health: Health{Reliability: 0.9}},
+		{Status: GameServerStatus{Name: "New"}},
+	}
+	SortEntriesByReliability(entries)
+	if entries[0].Status.Name != "Stable" || entries[1].Status.Name != "Flaky" || entries[2].Status.Name != "New" {
+		t.Errorf("SortEntriesByReliability(): %+v", entries)
+	}
+}
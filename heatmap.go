@@ -0,0 +1,104 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// HoursPerWeek is the number of buckets in a Heatmap.
+const HoursPerWeek = 7 * 24
+
+// Heatmap is a server's average population for each hour of the week, the
+// data behind "when is the game active" charts.  Buckets are indexed by
+// weekday (Sunday first) times 24 plus the hour.  It encodes as JSON with
+// encoding/json as is.
+type Heatmap struct {
+	Address string
+	// Players is the mean player count of the statuses in each bucket.
+	Players [HoursPerWeek]float64
+	// Samples is the number of statuses in each bucket.
+	Samples [HoursPerWeek]int
+}
+
+// BuildHeatmaps buckets statuses by server address and hour of the week of
+// their QueryTime in location, or UTC if location is nil.  The heatmaps are
+// sorted by address.
+func BuildHeatmaps(statuses []GameServerStatus, location *time.Location) (heatmaps []Heatmap) {
+	if location == nil {
+		location = time.UTC
+	}
+	byAddress := make(map[string]*Heatmap)
+	totals := make(map[string]*[HoursPerWeek]int)
+	for i := range statuses {
+		s := &statuses[i]
+		heatmap := byAddress[s.Address]
+		if heatmap == nil {
+			heatmap = &Heatmap{Address: s.Address}
+			byAddress[s.Address] = heatmap
+			totals[s.Address] = new([HoursPerWeek]int)
+		}
+		t := s.QueryTime.In(location)
+		bucket := int(t.Weekday())*24 + t.Hour()
+		heatmap.Samples[bucket]++
+		totals[s.Address][bucket] += int(s.NumPlayers)
+	}
+
+	for address, heatmap := range byAddress {
+		for bucket, samples := range heatmap.Samples {
+			if samples > 0 {
+				heatmap.Players[bucket] = float64(totals[address][bucket]) / float64(samples)
+			}
+		}
+		heatmaps = append(heatmaps, *heatmap)
+	}
+	sort.Slice(heatmaps, func(i, j int) bool { return heatmaps[i].Address < heatmaps[j].Address })
+	return
+}
+
+// WriteHeatmapsCSV writes heatmaps as CSV with an address, weekday, hour,
+// players, samples header and one row per bucket that has samples.
+func WriteHeatmapsCSV(w io.Writer, heatmaps []Heatmap) (err error) {
+	writer := csv.NewWriter(w)
+	err = writer.Write([]string{"address", "weekday", "hour", "players", "samples"})
+	if err != nil {
+		return
+	}
+	for i := range heatmaps {
+		for bucket, samples := range heatmaps[i].Samples {
+			if samples == 0 {
+				continue
+			}
+			err = writer.Write([]string{
+				heatmaps[i].Address,
+				time.Weekday(bucket / 24).String(),
+				strconv.Itoa(bucket % 24),
+				strconv.FormatFloat(heatmaps[i].Players[bucket], 'f', 2, 64),
+				strconv.Itoa(samples),
+			})
+			if err != nil {
+				return
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
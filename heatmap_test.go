@@ -0,0 +1,59 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestBuildHeatmaps(t *testing.T) {
+	// 2022-01-02 was a Sunday.
+	sunday := time.Date(2022, 1, 2, 20, 15, 0, 0, time.UTC)
+	statuses := []GameServerStatus{
+		{Address: "2.2.2.2:28001", NumPlayers: 4, QueryTime: sunday},
+		{Address: "1.1.1.1:28001", NumPlayers: 10, QueryTime: sunday},
+		{Address: "1.1.1.1:28001", NumPlayers: 20, QueryTime: sunday.Add(30 * time.Minute)},
+		{Address: "1.1.1.1:28001", NumPlayers: 6, QueryTime: sunday.Add(7 * 24 * time.Hour)},
+		{Address: "1.1.1.1:28001", NumPlayers: 2, QueryTime: sunday.Add(25 * time.Hour)},
+	}
+
+	heatmaps := BuildHeatmaps(statuses, nil)
+	if len(heatmaps) != 2 || heatmaps[0].Address != "1.1.1.1:28001" || heatmaps[1].Address != "2.2.2.2:28001" {
+		t.Fatalf("BuildHeatmaps(): %v", heatmaps)
+	}
+	if heatmaps[0].Samples[20] != 3 || heatmaps[0].Players[20] != 12 {
+		t.Errorf("Sunday 20:00: %v players from %d samples", heatmaps[0].Players[20], heatmaps[0].Samples[20])
+	}
+	if heatmaps[0].Samples[24+21] != 1 || heatmaps[0].Players[24+21] != 2 {
+		t.Errorf("Monday 21:00: %v players from %d samples", heatmaps[0].Players[24+21], heatmaps[0].Samples[24+21])
+	}
+
+	var buffer bytes.Buffer
+	err := WriteHeatmapsCSV(&buffer, heatmaps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "address,weekday,hour,players,samples\n" +
+		"1.1.1.1:28001,Sunday,20,12.00,3\n" +
+		"1.1.1.1:28001,Monday,21,2.00,1\n" +
+		"2.2.2.2:28001,Sunday,20,4.00,1\n"
+	if buffer.String() != expected {
+		t.Errorf("WriteHeatmapsCSV(): %q != %q", buffer.String(), expected)
+	}
+}
@@ -0,0 +1,111 @@
+//go:build go1.23
+
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"context"
+	"iter"
+)
+
+// The slices ranged over below are only ever replaced or appended to after
+// being reset, never modified in place, so they can be iterated without
+// holding the mutex or copying them first.
+
+// AllServers returns an iterator over the addresses returned by the last
+// query, in the order the master listed them.
+func (m *MasterServer) AllServers() iter.Seq[string] {
+	m.mutex.RLock()
+	servers := m.servers
+	m.mutex.RUnlock()
+	return func(yield func(string) bool) {
+		for _, server := range servers {
+			if !yield(server) {
+				return
+			}
+		}
+	}
+}
+
+// AllTeams returns an iterator over the teams of the last query, indexed by
+// team number.
+func (g *GameServer) AllTeams() iter.Seq2[int, Team] {
+	g.mutex.RLock()
+	teams := g.teams
+	g.mutex.RUnlock()
+	return func(yield func(int, Team) bool) {
+		for i, team := range teams {
+			if !yield(i, team) {
+				return
+			}
+		}
+	}
+}
+
+// AllPlayers returns an iterator over the players of the last query.
+func (g *GameServer) AllPlayers() iter.Seq[Player] {
+	g.mutex.RLock()
+	players := g.players
+	g.mutex.RUnlock()
+	return func(yield func(Player) bool) {
+		for _, player := range players {
+			if !yield(player) {
+				return
+			}
+		}
+	}
+}
+
+// Results refreshes the browser like Refresh and returns an iterator over
+// the result of each game server query, keyed by address, in the order they
+// complete, so a server list can be rendered as it fills in.  Stopping the
+// iteration early cancels the queries still pending.  If Refresh fails
+// before any game server is queried, the error is yielded once with an
+// empty address.
+func (b *ServerBrowser) Results(ctx context.Context) iter.Seq2[string, QueryResult] {
+	return func(yield func(string, QueryResult) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		results := make(chan QueryResult)
+		done := make(chan error, 1)
+		go func() {
+			done <- b.refreshEach(ctx, func(result QueryResult) {
+				select {
+				case results <- result:
+				case <-ctx.Done():
+				}
+			})
+			close(results)
+		}()
+
+		yielded := false
+		for result := range results {
+			yielded = true
+			if !yield(result.Address, result) {
+				cancel()
+				for range results {
+				}
+				return
+			}
+		}
+		if err := <-done; err != nil && !yielded {
+			yield("", QueryResult{Err: err})
+		}
+	}
+}
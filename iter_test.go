@@ -0,0 +1,114 @@
+//go:build go1.23
+
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIterators(t *testing.T) {
+	master := NewMasterServer("127.0.0.1:28000")
+	err := master.LoadReply([]byte{
+		0x10, 0x6, 1, 1, 0x12, 0x34, 0x0, 0x66,
+		6, 'M', 'a', 's', 't', 'e', 'r',
+		4, 'M', 'O', 'T', 'D',
+		0, 2,
+		6, 12, 13, 14, 15, 97, 109,
+		6, 22, 23, 24, 25, 97, 109,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var servers []string
+	for server := range master.AllServers() {
+		servers = append(servers, server)
+		break
+	}
+	if len(servers) != 1 || servers[0] != "12.13.14.15:28001" {
+		t.Errorf("master.AllServers(): %v", servers)
+	}
+
+	status := GameServerStatus{
+		Name:    "Iterated",
+		Game:    "Tribes",
+		Version: "1.11",
+		Teams:   []Team{{Name: "Blood Eagle"}, {Name: "Diamond Sword"}},
+		Players: []Player{{Name: "Alpha", Team: 1}, {Name: "Beta"}},
+	}
+	var buffer bytes.Buffer
+	err = status.WriteReply(&buffer, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGameServer("127.0.0.1:28001")
+	err = game.LoadReply(buffer.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, team := range game.AllTeams() {
+		if team != status.Teams[i] {
+			t.Errorf("game.AllTeams(): %d: %v != %v", i, team, status.Teams[i])
+		}
+	}
+	var players []Player
+	for player := range game.AllPlayers() {
+		players = append(players, player)
+	}
+	if len(players) != 2 || players[0].Name != "Alpha" || players[1].Name != "Beta" {
+		t.Errorf("game.AllPlayers(): %v", players)
+	}
+}
+
+func TestServerBrowserResults(t *testing.T) {
+	closer := serveMasterList(t, "127.0.0.1:28963", 28962, 28961)
+	defer closer()
+	for _, address := range []string{"127.0.0.1:28962", "127.0.0.1:28961"} {
+		startResponder(t, address, GameServerStatus{Name: address, Game: "Tribes", Version: "1.11"})
+	}
+
+	browser := NewServerBrowser("127.0.0.1:28963")
+	browser.SetTimeout(time.Second)
+	results := make(map[string]QueryResult)
+	for address, result := range browser.Results(context.Background()) {
+		results[address] = result
+	}
+	if len(results) != 2 || results["127.0.0.1:28962"].Status.Name != "127.0.0.1:28962" || results["127.0.0.1:28961"].Err != nil {
+		t.Errorf("browser.Results(): %+v", results)
+	}
+
+	count := 0
+	for range browser.Results(context.Background()) {
+		count++
+		break
+	}
+	if count != 1 {
+		t.Errorf("browser.Results() yielded %d results after break", count)
+	}
+
+	failing := NewServerBrowser("127.0.0.1:28960")
+	failing.SetTimeout(50 * time.Millisecond)
+	for address, result := range failing.Results(context.Background()) {
+		if address != "" || result.Err == nil {
+			t.Errorf("failing.Results(): %s %+v", address, result)
+		}
+	}
+}
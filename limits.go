@@ -0,0 +1,62 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+// Limits bounds what a reply may contain before it is rejected.  A zero
+// field uses the value from DefaultLimits.
+type Limits struct {
+	// MaxPlayers is the most players a game server reply may list.  The
+	// default of 255, the most the reply's one byte count can hold, accepts
+	// every well-formed reply.
+	MaxPlayers int
+	// MaxTeams is the most teams a game server reply may list.
+	MaxTeams int
+	// MaxStringLength is the longest string accepted in any reply.
+	MaxStringLength int
+	// MaxServers is the most servers accepted across all master packets.
+	MaxServers int
+	// MaxPackets is the most packets a master reply may be split into.
+	MaxPackets int
+}
+
+var DefaultLimits = Limits{
+	MaxPlayers:      255,
+	MaxTeams:        32,
+	MaxStringLength: 255,
+	MaxServers:      4096,
+	MaxPackets:      5,
+}
+
+// withDefaults returns l with every zero field replaced by its default.
+func (l Limits) withDefaults() Limits {
+	if l.MaxPlayers == 0 {
+		l.MaxPlayers = DefaultLimits.MaxPlayers
+	}
+	if l.MaxTeams == 0 {
+		l.MaxTeams = DefaultLimits.MaxTeams
+	}
+	if l.MaxStringLength == 0 {
+		l.MaxStringLength = DefaultLimits.MaxStringLength
+	}
+	if l.MaxServers == 0 {
+		l.MaxServers = DefaultLimits.MaxServers
+	}
+	if l.MaxPackets == 0 {
+		l.MaxPackets = DefaultLimits.MaxPackets
+	}
+	return l
+}
@@ -0,0 +1,75 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLimitsWithDefaults(t *testing.T) {
+	if (Limits{}).withDefaults() != DefaultLimits {
+		t.Fatalf("zero Limits did not use DefaultLimits: %v", (Limits{}).withDefaults())
+	}
+
+	limits := Limits{MaxPlayers: 1}.withDefaults()
+	if limits.MaxPlayers != 1 {
+		t.Errorf("limits.MaxPlayers: %d != 1", limits.MaxPlayers)
+	}
+	if limits.MaxTeams != DefaultLimits.MaxTeams {
+		t.Errorf("limits.MaxTeams: %d != %d", limits.MaxTeams, DefaultLimits.MaxTeams)
+	}
+}
+
+func TestGameServerLimits(t *testing.T) {
+	status := GameServerStatus{
+		Name:    "Crowded",
+		Players: []Player{{Name: "Alpha"}, {Name: "Beta"}},
+	}
+	closer := replyOnce(t, "127.0.0.1:28995", func(key uint16) []byte {
+		var buffer bytes.Buffer
+		err := status.WriteReply(&buffer, key)
+		if err != nil {
+			t.Error(err)
+		}
+		return buffer.Bytes()
+	})
+	defer closer()
+
+	game := NewGameServer("127.0.0.1:28995")
+	game.SetLimits(Limits{MaxPlayers: 1})
+	err := game.Query(0, "")
+	if err == nil {
+		t.Fatal("expected the player limit to be enforced")
+	}
+}
+
+func TestDefaultPlayerLimit(t *testing.T) {
+	status := GameServerStatus{Name: "Crowded"}
+	for i := 0; i < 255; i++ {
+		status.Players = append(status.Players, Player{Name: "P"})
+	}
+	var buffer bytes.Buffer
+	if err := status.WriteReply(&buffer, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	game := NewGameServer("127.0.0.1:28001")
+	if err := game.LoadReply(buffer.Bytes()); err != nil || game.NumPlayers() != 255 {
+		t.Errorf("LoadReply(): %v, %d players", err, game.NumPlayers())
+	}
+}
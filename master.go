@@ -18,14 +18,170 @@ package t1net
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math/rand"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 )
 
+// DuplicatePolicy decides which entries MergeServerLists treats as the same
+// server.
+type DuplicatePolicy int
+
+const (
+	// CollapseSameAddress collapses entries with the same IP and port.
+	CollapseSameAddress DuplicatePolicy = iota
+	// CollapseSameIP keeps only the first port seen for each IP, for lists
+	// where servers register several ports by mistake.
+	CollapseSameIP
+)
+
+// MergeServerLists combines the server lists of several masters, keeping the
+// first entry of each server in order.  Hostnames are resolved so that
+// "host:28001" and "1.2.3.4:28001" are recognised as the same server; entries
+// that fail to resolve are compared as written.
+func MergeServerLists(policy DuplicatePolicy, lists ...[]string) (servers []string) {
+	servers, _ = MergeServerListsContext(context.Background(), nil, policy, lists...)
+	return
+}
+
+// MergeServerListsContext is MergeServerLists resolving hostnames with
+// resolver, or net.DefaultResolver if it is nil.  It stops and returns
+// ctx.Err() once ctx is done.
+func MergeServerListsContext(ctx context.Context, resolver Resolver, policy DuplicatePolicy, lists ...[]string) (servers []string, err error) {
+	seen := make(map[string]bool)
+	for _, list := range lists {
+		for _, server := range list {
+			if err = contextDone(ctx); err != nil {
+				return nil, err
+			}
+
+			key := server
+			if candidates, resolveErr := resolveCandidates(ctx, resolver, server, "udp4"); resolveErr == nil {
+				key = candidates[0].String()
+				if policy == CollapseSameIP {
+					key = candidates[0].IP.String()
+				}
+			}
+
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			servers = append(servers, server)
+		}
+	}
+	return
+}
+
+// PartialReplyError is returned by MasterServer.QueryStatus when a query
+// fails after some but not all packets of the reply have arrived.  The
+// status returned with it holds the servers decoded from those packets;
+// the MasterServer itself keeps the results of its last complete reply.
+type PartialReplyError struct {
+	// Received and Total count the packets decoded and the packets the
+	// reply was split into.
+	Received int
+	Total    int
+	Err      error
+}
+
+func (e *PartialReplyError) Error() string {
+	return fmt.Sprintf("t1net.MasterServer.Query: Partial reply, %d of %d packets: %v", e.Received, e.Total, e.Err)
+}
+
+func (e *PartialReplyError) Unwrap() error {
+	return e.Err
+}
+
+// MasterServerStatus is a snapshot of a master server's reply.
+type MasterServerStatus struct {
+	Address     string
+	Ping        time.Duration
+	QueryTime   time.Time
+	Name        string
+	MOTD        string
+	ServerCount uint16
+	Servers     []string
+}
+
+// Age returns the time elapsed since QueryTime, or zero if it is not set.
+// See GameServerStatus.Age.
+func (s *MasterServerStatus) Age() (age time.Duration) {
+	if s.QueryTime.IsZero() {
+		return
+	}
+	return time.Since(s.QueryTime)
+}
+
+// masterPacketSize is the most bytes WriteReply puts in one packet, the
+// size of the buffer MasterServer reads the packets after the first into.
+const masterPacketSize = 1024
+
+// WriteReply encodes the status as the packets of a master server reply for
+// the given key, as many as are needed to keep each within 1024 bytes.
+// Servers must be IPv4 "ip:port" addresses.  ServerCount is ignored.
+func (s *MasterServerStatus) WriteReply(key uint16) (packets [][]byte, err error) {
+	if len(s.Name) > 255 || len(s.MOTD) > 255 {
+		return nil, errors.New("t1net.MasterServerStatus.WriteReply: Name or MOTD longer than 255 bytes")
+	}
+	perPacket := (masterPacketSize - 8 - 1 - len(s.Name) - 1 - len(s.MOTD) - 2) / 7
+	total := (len(s.Servers) + perPacket - 1) / perPacket
+	if total == 0 {
+		total = 1
+	}
+	if total > 255 {
+		return nil, fmt.Errorf("t1net.MasterServerStatus.WriteReply: Too many servers: %d", len(s.Servers))
+	}
+
+	servers := s.Servers
+	for p := 1; p <= total; p++ {
+		chunk := servers
+		if len(chunk) > perPacket {
+			chunk = chunk[:perPacket]
+		}
+		servers = servers[len(chunk):]
+
+		var buffer bytes.Buffer
+		buffer.Write([]byte{0x10, 0x06, byte(p), byte(total), 0x00, 0x00, 0x00, 0x66})
+		binary.BigEndian.PutUint16(buffer.Bytes()[4:6], key)
+		err = WritePascalString(&buffer, s.Name)
+		if err != nil {
+			return nil, err
+		}
+		err = WritePascalString(&buffer, s.MOTD)
+		if err != nil {
+			return nil, err
+		}
+		err = binary.Write(&buffer, binary.BigEndian, uint16(len(chunk)))
+		if err != nil {
+			return nil, err
+		}
+		for _, server := range chunk {
+			host, portString, splitErr := net.SplitHostPort(server)
+			ip := net.ParseIP(host).To4()
+			port, portErr := strconv.ParseUint(portString, 10, 16)
+			if splitErr != nil || ip == nil || portErr != nil {
+				return nil, fmt.Errorf("t1net.MasterServerStatus.WriteReply: Invalid server address %q", server)
+			}
+			err = WriteAddressPort(&buffer, ip, uint16(port))
+			if err != nil {
+				return nil, err
+			}
+		}
+		packets = append(packets, buffer.Bytes())
+	}
+	return
+}
+
+// MasterServer queries a master server for its server list and holds the
+// results of the last successful query.  Like GameServer, it is safe for
+// concurrent use and can be reconfigured while queries are running.
 type MasterServer struct {
 	mutex        sync.RWMutex
 	address      string
@@ -38,20 +194,50 @@ type MasterServer struct {
 	ping         time.Duration
 	queryTime    time.Time
 	totalPackets int
+	limits       Limits
+	clientID     uint16
+	minRefresh   time.Duration
+	lastRefresh  time.Time
+	lastErr      error
+	lastSuccess  time.Time
+	timeout      time.Duration
+	retries      int
+	backoff      time.Duration
+	tracing      bool
+	trace        *QueryTrace
+	resolved     *resolvedCandidates
+	quarantine   *Quarantine
 }
 
+// Ping returns the round trip time of the first reply packet of the last
+// query, measured on the monotonic clock from sending the request.  Looking
+// up the master's host is not included.
 func (m *MasterServer) Ping() (ping time.Duration) {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 	return m.ping
 }
 
+// QueryTime returns the wall clock time the last query was sent.  Use Age to
+// measure how long ago that was.
 func (m *MasterServer) QueryTime() (queryTime time.Time) {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 	return m.queryTime
 }
 
+// Age returns the time elapsed since the last query was sent, measured on
+// the monotonic clock so that wall clock adjustments do not skew it.  It
+// returns zero if the master has not been queried.
+func (m *MasterServer) Age() (age time.Duration) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if m.queryTime.IsZero() {
+		return
+	}
+	return time.Since(m.queryTime)
+}
+
 func (m *MasterServer) Name() (name string) {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
@@ -78,39 +264,264 @@ func (m *MasterServer) Servers() (servers []string) {
 	return
 }
 
-func (m *MasterServer) Query(timeout time.Duration, localAddress string) (err error) {
-	if timeout == 0 {
-		timeout = 5 * time.Second
+// Status returns a snapshot of the last reply, sharing nothing with m.
+func (m *MasterServer) Status() (status MasterServerStatus) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.status()
+}
+
+func (m *MasterServer) status() (status MasterServerStatus) {
+	status = MasterServerStatus{
+		Address:     m.address,
+		Ping:        m.ping,
+		QueryTime:   m.queryTime,
+		Name:        m.name,
+		MOTD:        m.motd,
+		ServerCount: m.serverCount,
 	}
+	if m.servers != nil {
+		status.Servers = make([]string, len(m.servers))
+		copy(status.Servers, m.servers)
+	}
+	return
+}
+
+func (m *MasterServer) Limits() (limits Limits) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.limits.withDefaults()
+}
 
-	var localAddr *net.UDPAddr
+func (m *MasterServer) SetLimits(limits Limits) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.limits = limits
+}
 
-	if len(localAddress) != 0 {
-		localAddr, err = net.ResolveUDPAddr("udp4", localAddress)
-		if err != nil {
-			return
-		}
+func (m *MasterServer) ClientID() (clientID uint16) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.clientID
+}
+
+// SetClientID sets the ID bytes sent in future requests, which some masters
+// log to tell clients apart.  The default is 0.
+func (m *MasterServer) SetClientID(clientID uint16) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.clientID = clientID
+}
+
+// SetTracing makes future queries record a QueryTrace, returned by Trace.
+func (m *MasterServer) SetTracing(enabled bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.tracing = enabled
+}
+
+// Trace returns the trace of the last query made with tracing enabled, or
+// nil if there is none.
+func (m *MasterServer) Trace() (trace *QueryTrace) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.trace
+}
+
+// SetTimeout sets the timeout Query uses when called with a zero timeout.
+// The default is 5 seconds.
+func (m *MasterServer) SetTimeout(timeout time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.timeout = timeout
+}
+
+// SetRetries makes Query send the query again, with a fresh key, up to
+// retries more times while it times out.  It waits backoff before the first
+// retry and doubles the wait before each one after it.  The default is no
+// retries.
+func (m *MasterServer) SetRetries(retries int, backoff time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.retries = retries
+	m.backoff = backoff
+}
+
+func (m *MasterServer) SetMinRefreshInterval(interval time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.minRefresh = interval
+}
+
+// Refresh queries the master straight away.  It returns ErrRefreshLimited if
+// called again within the interval set with SetMinRefreshInterval.
+func (m *MasterServer) Refresh(timeout time.Duration, localAddress string) (err error) {
+	m.mutex.Lock()
+	if m.minRefresh > 0 && !m.lastRefresh.IsZero() && time.Since(m.lastRefresh) < m.minRefresh {
+		m.mutex.Unlock()
+		return ErrRefreshLimited
 	}
+	m.lastRefresh = time.Now()
+	m.mutex.Unlock()
+
+	return m.Query(timeout, localAddress)
+}
+
+// Query requests the server list and replaces the results held by m with
+// the reply, like GameServer.Query.
+func (m *MasterServer) Query(timeout time.Duration, localAddress string) (err error) {
+	return m.QueryContext(context.Background(), timeout, localAddress)
+}
+
+// QueryContext is Query with a context: the query is abandoned with
+// ctx.Err() once ctx is done, and the timeout is shortened to ctx's
+// deadline.
+func (m *MasterServer) QueryContext(ctx context.Context, timeout time.Duration, localAddress string) (err error) {
+	return m.QueryWith(ctx, WithQueryTimeout(timeout), WithLocalAddr(localAddress))
+}
 
-	remoteAddr, err := net.ResolveUDPAddr("udp4", m.address)
+// Resolve looks up the addresses the master's host resolves to and keeps
+// them for later queries on the same network.  See GameServer.Resolve.
+func (m *MasterServer) Resolve(ctx context.Context, options ...QueryOption) (err error) {
+	o := newQueryOptions(queryOptions{}, options)
+	candidates, err := resolveCandidates(ctx, o.resolver, m.address, o.network)
 	if err != nil {
 		return
 	}
 
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
+	m.resolved = &resolvedCandidates{network: o.network, candidates: candidates}
+	return
+}
 
-	m.ip = remoteAddr.IP
-	m.port = remoteAddr.Port
-	m.serverCount = 0
-	m.servers = nil
+// QueryWith is QueryContext configured with options.
+func (m *MasterServer) QueryWith(ctx context.Context, options ...QueryOption) (err error) {
+	_, err = m.QueryStatus(ctx, options...)
+	return
+}
+
+// QueryStatus is QueryWith returning a snapshot of the server list it
+// received, taken before any other query can replace it.  If the query fails
+// after part of a reply split into several packets has arrived, status holds
+// the servers decoded so far and err is a *PartialReplyError.
+func (m *MasterServer) QueryStatus(ctx context.Context, options ...QueryOption) (status MasterServerStatus, err error) {
+	m.mutex.RLock()
+	o := newQueryOptions(queryOptions{timeout: m.timeout, retries: m.retries, backoff: m.backoff}, options)
+	tracing := m.tracing
+	m.mutex.RUnlock()
+
+	// One trace covers every attempt, so retries show up in it.
+	trace := newTrace(ctx, tracing, o.packetHook, m.address)
+	if trace != nil && trace.recording {
+		defer func() {
+			trace.Done, trace.Err = time.Now(), err
+			m.mutex.Lock()
+			defer m.mutex.Unlock()
+			m.trace = trace
+		}()
+	}
+
+	err = retry(ctx, o.retries, o.backoff, func() (err error) {
+		status, err = m.query(ctx, o, trace)
+		return
+	})
+	if err != nil {
+		if ctxErr := contextDone(ctx); ctxErr != nil {
+			var partial *PartialReplyError
+			if errors.As(err, &partial) {
+				partial.Err = ctxErr
+				return
+			}
+			return status, ctxErr
+		}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.lastErr = err
+	if err == nil {
+		m.lastSuccess = time.Now()
+	}
+	return
+}
+
+// Online reports whether the last query was answered, like
+// GameServer.Online.
+func (m *MasterServer) Online() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.lastErr == nil && !m.lastSuccess.IsZero()
+}
+
+// LastError returns the error of the last query, or nil if it succeeded.
+func (m *MasterServer) LastError() (err error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.lastErr
+}
 
-	c, err := net.DialUDP("udp4", localAddr, remoteAddr)
+// LastSuccess returns when the last successful query completed, or the zero
+// time if none has.
+func (m *MasterServer) LastSuccess() (lastSuccess time.Time) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.lastSuccess
+}
+
+func (m *MasterServer) query(ctx context.Context, o queryOptions, trace *QueryTrace) (status MasterServerStatus, err error) {
+	timeout, err := contextTimeout(ctx, o.timeout)
 	if err != nil {
 		return
 	}
 
-	defer c.Close()
+	m.mutex.RLock()
+	r := &MasterServer{address: m.address, limits: m.limits, clientID: m.clientID, quarantine: o.quarantine}
+	resolved := m.resolved
+	m.mutex.RUnlock()
+
+	// The query fills in r without holding m's lock, so readers of m are
+	// not blocked for the length of the exchange.
+	defer func() {
+		if err == nil {
+			m.mutex.Lock()
+			defer m.mutex.Unlock()
+			m.adopt(r)
+			status = m.status()
+		}
+	}()
+
+	limits := r.limits.withDefaults()
+	if o.simulation != nil {
+		key := uint16(rand.Uint32())
+		var packets [][]byte
+		r.queryTime = time.Now()
+		packets, r.ping, err = o.simulation.masterReply(ctx, m.address, key, timeout)
+		if err != nil {
+			return
+		}
+		for _, packet := range packets {
+			err = r.parsePacket(packet, key, true, limits)
+			if err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	dial, err := o.dialer()
+	if err != nil {
+		return
+	}
+
+	candidates, err := resolved.lookup(ctx, m.address, o)
+	if err != nil {
+		return
+	}
+	trace.resolved(candidates)
+
+	r.ip = candidates[0].IP
+	r.port = candidates[0].Port
 
 	key := uint16(rand.Uint32())
 	sendBuffer := []byte{
@@ -125,144 +536,260 @@ func (m *MasterServer) Query(timeout time.Duration, localAddress string) (err er
 	}
 
 	binary.BigEndian.PutUint16(sendBuffer[4:6], key)
+	binary.BigEndian.PutUint16(sendBuffer[6:8], r.clientID)
 
-	m.queryTime = time.Now()
-	pingCalculated := false
-	_, err = c.Write(sendBuffer)
-	if err != nil {
-		return
-	}
-
-	recvBuf := make([]byte, 1024)
-	m.totalPackets = 1
+	r.queryTime = time.Now()
 	var (
-		n                            int
-		addr                         *net.UDPAddr
-		b, packetNumber, packetTotal byte
-		ip                           net.IP
-		port                         uint16
+		c          net.PacketConn
+		reply      []byte
+		remoteAddr *net.UDPAddr
 	)
-	for p := 0; p < m.totalPackets; p++ {
-		err = c.SetDeadline(time.Now().Add(timeout))
+	if o.conn != nil {
+		c = o.conn
+		remoteAddr, err = reachableCandidate(candidates, c)
 		if err != nil {
 			return
 		}
-		n, addr, err = c.ReadFromUDP(recvBuf)
+		defer stopOnDone(ctx, c, false)()
+		reply, r.ping, err = exchange(c, remoteAddr, sendBuffer, timeout, trace)
 		if err != nil {
 			return
 		}
-
-		if !addr.IP.Equal(remoteAddr.IP) || addr.Port != remoteAddr.Port {
-			return fmt.Errorf("t1net.MasterServer.Query: Reply address mismatch: %s != %s", remoteAddr.String(), addr.String())
-		}
-
-		if !pingCalculated {
-			pingCalculated = true
-			m.ping = time.Since(m.queryTime)
+	} else {
+		var conn net.Conn
+		conn, remoteAddr, reply, r.ping, err = dialFirst(ctx, dial, o.network, candidates, sendBuffer, timeout, trace)
+		if err != nil {
+			return
 		}
+		defer conn.Close()
+		defer stopOnDone(ctx, conn, true)()
+		c = packetConn{Conn: conn, remoteAddr: remoteAddr}
+	}
+	r.ip = remoteAddr.IP
+	r.port = remoteAddr.Port
 
-		reader := bytes.NewReader(recvBuf[0:n])
+	r.totalPackets = 1
+	err = r.parsePacket(reply, key, true, limits)
+	if err != nil {
+		return
+	}
 
-		b, err = reader.ReadByte()
+	received := 1
+	defer func() {
 		if err != nil {
-			return
-		}
-		if b != 0x10 {
-			return fmt.Errorf("t1net.MasterServer.Query: Reply byte 0: %#v != 0x10", b)
+			status = r.status()
+			err = &PartialReplyError{Received: received, Total: r.totalPackets, Err: err}
 		}
+	}()
 
-		b, err = reader.ReadByte()
-		if err != nil {
+	recvBuf := make([]byte, 1024)
+	var (
+		n    int
+		addr net.Addr
+	)
+	for p := 1; p < r.totalPackets; p++ {
+		// Stop between packets once ctx is done, and do not wait past its
+		// deadline for the next one.
+		if err = contextDone(ctx); err != nil {
 			return
 		}
-		if b != 0x06 {
-			return fmt.Errorf("t1net.MasterServer.Query: Reply byte 1: %#v != 0x06", b)
+		deadline := time.Now().Add(timeout)
+		if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
 		}
-
-		// Packet Number
-		packetNumber, err = reader.ReadByte()
+		err = c.SetReadDeadline(deadline)
 		if err != nil {
 			return
 		}
-		if packetNumber < 1 || packetNumber > 5 {
-			return fmt.Errorf("t1net.MasterServer.Query: Invalid packet number: %d", packetNumber)
-		}
-
-		// Total number of Packets
-		packetTotal, err = reader.ReadByte()
+		n, addr, err = c.ReadFrom(recvBuf)
 		if err != nil {
-			return err
-		}
-		if packetTotal < 1 || packetTotal > 5 {
-			return fmt.Errorf("t1net.MasterServer.Query: Invalid total packet number: %d", packetTotal)
+			return
 		}
 
-		if packetNumber > packetTotal {
-			return fmt.Errorf("t1net.MasterServer.Query: Packet Number is greater than total: %d / %d", packetNumber, packetTotal)
+		// Packets from other addresses can arrive on a shared socket.
+		if !sameAddress(addr, remoteAddr) {
+			p--
+			continue
 		}
+		trace.packet(false, addr, recvBuf[0:n])
 
-		var recvKey uint16
-		err = binary.Read(reader, binary.BigEndian, &recvKey)
+		err = r.parsePacket(recvBuf[0:n], key, true, limits)
 		if err != nil {
 			return
 		}
-		if key != recvKey {
-			return fmt.Errorf("t1net.MasterServer.Query: Key mismatch: %d : %d", recvKey, key)
-		}
+		received++
+	}
 
-		m.totalPackets = int(packetTotal)
+	return
+}
 
-		b, err = reader.ReadByte()
-		if err != nil {
-			return
-		}
-		if b != 0 {
-			return fmt.Errorf("t1net.MasterServer.Query: Reply byte 6: %#v != 0x00", b)
-		}
+// adopt replaces m's results with those of r, filled in by a query.
+func (m *MasterServer) adopt(r *MasterServer) {
+	m.ip = r.ip
+	m.port = r.port
+	m.ping = r.ping
+	m.queryTime = r.queryTime
+	m.name = r.name
+	m.motd = r.motd
+	m.serverCount = r.serverCount
+	m.servers = r.servers
+	m.totalPackets = r.totalPackets
+}
 
-		b, err = reader.ReadByte()
-		if err != nil {
-			return
-		}
-		if b != 0x66 {
-			return fmt.Errorf("t1net.MasterServer.Query: Reply byte 7: %#v != 0x66", b)
-		}
+// parsePacket decodes one packet of a master server reply into m.  The key
+// is only compared when checkKey is set.  A panic while parsing is returned
+// as a MalformedReplyError.  Packets that fail to parse are stored in m's
+// quarantine, if any.
+func (m *MasterServer) parsePacket(packet []byte, key uint16, checkKey bool, limits Limits) (err error) {
+	defer func() {
+		m.quarantine.add("master", m.address, packet, err)
+	}()
+	defer recoverMalformed(packet, &err)
 
-		m.name, err = ReadPascalString(reader)
-		if err != nil {
-			return
-		}
+	var (
+		b, packetNumber, packetTotal byte
+		ip                           net.IP
+		port                         uint16
+	)
 
-		m.motd, err = ReadPascalString(reader)
-		if err != nil {
-			return
-		}
+	reader := bytes.NewReader(packet)
+
+	b, err = reader.ReadByte()
+	if err != nil {
+		return
+	}
+	if b != 0x10 {
+		return fmt.Errorf("t1net.MasterServer.Query: Reply byte 0: %#v != 0x10", b)
+	}
+
+	b, err = reader.ReadByte()
+	if err != nil {
+		return
+	}
+	if b != 0x06 {
+		return fmt.Errorf("t1net.MasterServer.Query: Reply byte 1: %#v != 0x06", b)
+	}
+
+	// Packet Number
+	packetNumber, err = reader.ReadByte()
+	if err != nil {
+		return
+	}
+	if packetNumber < 1 || int(packetNumber) > limits.MaxPackets {
+		return fmt.Errorf("t1net.MasterServer.Query: Invalid packet number: %d", packetNumber)
+	}
+
+	// Total number of Packets
+	packetTotal, err = reader.ReadByte()
+	if err != nil {
+		return
+	}
+	if packetTotal < 1 || int(packetTotal) > limits.MaxPackets {
+		return fmt.Errorf("t1net.MasterServer.Query: Invalid total packet number: %d", packetTotal)
+	}
+
+	if packetNumber > packetTotal {
+		return fmt.Errorf("t1net.MasterServer.Query: Packet Number is greater than total: %d / %d", packetNumber, packetTotal)
+	}
+
+	var recvKey uint16
+	err = binary.Read(reader, binary.BigEndian, &recvKey)
+	if err != nil {
+		return
+	}
+	if checkKey && key != recvKey {
+		return fmt.Errorf("t1net.MasterServer.Query: Key mismatch: %d : %d", recvKey, key)
+	}
+
+	m.totalPackets = int(packetTotal)
+
+	b, err = reader.ReadByte()
+	if err != nil {
+		return
+	}
+	if b != 0 {
+		return fmt.Errorf("t1net.MasterServer.Query: Reply byte 6: %#v != 0x00", b)
+	}
+
+	b, err = reader.ReadByte()
+	if err != nil {
+		return
+	}
+	if b != 0x66 {
+		return fmt.Errorf("t1net.MasterServer.Query: Reply byte 7: %#v != 0x66", b)
+	}
+
+	m.name, err = ReadPascalString(reader)
+	if err != nil {
+		return
+	}
+	if len(m.name) > limits.MaxStringLength {
+		return fmt.Errorf("t1net.MasterServer.Query: Name length exceeds limit: %d > %d", len(m.name), limits.MaxStringLength)
+	}
+
+	m.motd, err = ReadPascalString(reader)
+	if err != nil {
+		return
+	}
+	if len(m.motd) > limits.MaxStringLength {
+		return fmt.Errorf("t1net.MasterServer.Query: MOTD length exceeds limit: %d > %d", len(m.motd), limits.MaxStringLength)
+	}
 
-		var serverCount uint16
-		err = binary.Read(reader, binary.BigEndian, &serverCount)
+	var serverCount uint16
+	err = binary.Read(reader, binary.BigEndian, &serverCount)
+	if err != nil {
+		return
+	}
+
+	if int(m.serverCount)+int(serverCount) > limits.MaxServers {
+		return fmt.Errorf("t1net.MasterServer.Query: Server count exceeds limit: %d > %d", int(m.serverCount)+int(serverCount), limits.MaxServers)
+	}
+	m.serverCount += serverCount
+
+	for i := uint16(0); i < serverCount; i++ {
+		ip, port, err = ReadAddressPort(reader)
 		if err != nil {
 			return
 		}
 
-		m.serverCount += serverCount
+		m.servers = append(m.servers, fmt.Sprintf("%s:%d", ip.String(), port))
+	}
+
+	if reader.Len() != 0 {
+		return fmt.Errorf("t1net.MasterServer.Query: %d left over bytes", reader.Len())
+	}
 
-		for i := uint16(0); i < serverCount; i++ {
-			ip, port, err = ReadAddressPort(reader)
-			if err != nil {
-				return
-			}
+	return
+}
 
-			m.servers = append(m.servers, fmt.Sprintf("%s:%d", ip.String(), port))
-		}
+// LoadReply decodes recorded reply packets as if they had been received by
+// Query, replacing the current state.  The ping and query time are left
+// unchanged and the key is not checked.
+func (m *MasterServer) LoadReply(packets ...[]byte) (err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	limits := m.limits.withDefaults()
+	m.serverCount = 0
+	m.servers = nil
+	m.totalPackets = 1
 
-		if reader.Len() != 0 {
-			return fmt.Errorf("t1net.MasterServer.Query: %d left over bytes", reader.Len())
+	for _, packet := range packets {
+		err = m.parsePacket(packet, 0, false, limits)
+		if err != nil {
+			return
 		}
 	}
 
+	if len(packets) != m.totalPackets {
+		return fmt.Errorf("t1net.MasterServer.LoadReply: Expected %d packets, got %d", m.totalPackets, len(packets))
+	}
+
 	return
 }
 
+// NewMasterServer returns a MasterServer for address, normalized with
+// NormalizeAddress and DefaultMasterPort.
 func NewMasterServer(address string) *MasterServer {
-	return &MasterServer{address: address}
+	return &MasterServer{address: normalizeOrKeep(address, DefaultMasterPort)}
 }
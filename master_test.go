@@ -18,6 +18,8 @@ package t1net
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"math/rand"
 	"net"
 	"testing"
@@ -224,3 +226,191 @@ func TestMasterServer(t *testing.T) {
 		}
 	}
 }
+
+func TestMergeServerLists(t *testing.T) {
+	a := []string{"12.13.14.15:28001", "12.13.14.15:28002", "localhost:28001"}
+	b := []string{"12.13.14.15:28001", "127.0.0.1:28001", "22.23.24.25:28001"}
+
+	merged := MergeServerLists(CollapseSameAddress, a, b)
+	expected := []string{"12.13.14.15:28001", "12.13.14.15:28002", "localhost:28001", "22.23.24.25:28001"}
+	if len(merged) != len(expected) {
+		t.Fatalf("MergeServerLists(CollapseSameAddress): %v != %v", merged, expected)
+	}
+	for i := range merged {
+		if merged[i] != expected[i] {
+			t.Fatalf("MergeServerLists(CollapseSameAddress): %v != %v", merged, expected)
+		}
+	}
+
+	merged = MergeServerLists(CollapseSameIP, a, b)
+	expected = []string{"12.13.14.15:28001", "localhost:28001", "22.23.24.25:28001"}
+	if len(merged) != len(expected) {
+		t.Fatalf("MergeServerLists(CollapseSameIP): %v != %v", merged, expected)
+	}
+	for i := range merged {
+		if merged[i] != expected[i] {
+			t.Fatalf("MergeServerLists(CollapseSameIP): %v != %v", merged, expected)
+		}
+	}
+}
+
+func TestMergeServerListsContext(t *testing.T) {
+	resolver := &countingResolver{hosts: map[string]net.IP{"tribes.example": net.IPv4(192, 0, 2, 1)}}
+	a := []string{"tribes.example:28001", "192.0.2.2:28001"}
+	b := []string{"192.0.2.1:28001", "missing.example:28001"}
+
+	merged, err := MergeServerListsContext(context.Background(), resolver, CollapseSameAddress, a, b)
+	expected := []string{"tribes.example:28001", "192.0.2.2:28001", "missing.example:28001"}
+	if err != nil || len(merged) != len(expected) {
+		t.Fatalf("MergeServerListsContext(): %v, %v != %v", merged, err, expected)
+	}
+	for i := range merged {
+		if merged[i] != expected[i] {
+			t.Fatalf("MergeServerListsContext(): %v != %v", merged, expected)
+		}
+	}
+	if resolver.lookups != 4 {
+		t.Errorf("lookups: %v != 4", resolver.lookups)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if merged, err = MergeServerListsContext(ctx, resolver, CollapseSameAddress, a, b); !errors.Is(err, context.Canceled) || merged != nil {
+		t.Errorf("MergeServerListsContext() cancelled: %v, %v", merged, err)
+	}
+}
+
+func TestMasterServerLoadReply(t *testing.T) {
+	packets := [][]byte{
+		{
+			0x10, 0x6, 1, 2, 0x12, 0x34, 0x0, 0x66,
+			6, 'M', 'a', 's', 't', 'e', 'r',
+			4, 'M', 'O', 'T', 'D',
+			0, 1,
+			6, 12, 13, 14, 15, 97, 109,
+		},
+		{
+			0x10, 0x6, 2, 2, 0x12, 0x34, 0x0, 0x66,
+			6, 'M', 'a', 's', 't', 'e', 'r',
+			4, 'M', 'O', 'T', 'D',
+			0, 1,
+			6, 22, 23, 24, 25, 97, 109,
+		},
+	}
+
+	master := NewMasterServer("127.0.0.1:28000")
+	err := master.LoadReply(packets...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if master.Name() != "Master" || master.MOTD() != "MOTD" {
+		t.Errorf("master.Name(): %s, master.MOTD(): %s", master.Name(), master.MOTD())
+	}
+	servers := master.Servers()
+	if len(servers) != 2 || servers[0] != "12.13.14.15:28001" || servers[1] != "22.23.24.25:28001" {
+		t.Errorf("master.Servers(): %v", servers)
+	}
+	status := master.Status()
+	if status.Name != "Master" || status.ServerCount != 2 || len(status.Servers) != 2 {
+		t.Errorf("master.Status(): %+v", status)
+	}
+	status.Servers[0] = "changed"
+	if master.Servers()[0] != "12.13.14.15:28001" {
+		t.Errorf("master.Status() shares Servers with master")
+	}
+
+	err = master.LoadReply(packets[0])
+	if err == nil {
+		t.Fatal("expected an error for a missing packet")
+	}
+}
+
+func TestMasterServerClientID(t *testing.T) {
+	s, err := net.ResolveUDPAddr("udp4", "127.0.0.1:28993")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := net.ListenUDP("udp4", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	go func() {
+		readBuffer := make([]byte, 64)
+		err := c.SetDeadline(time.Now().Add(1 * time.Second))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		n, addr, err := c.ReadFromUDP(readBuffer)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if n != 8 || readBuffer[6] != 0xbe || readBuffer[7] != 0xef {
+			t.Errorf("Client ID not sent: %v", readBuffer[0:n])
+		}
+		sendBuffer := []byte{0x10, 0x6, 1, 1, readBuffer[4], readBuffer[5], 0x0, 0x66, 0, 0, 0, 0}
+		_, err = c.WriteToUDP(sendBuffer, addr)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	master := NewMasterServer("127.0.0.1:28993")
+	master.SetClientID(0xbeef)
+	err = master.Query(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMasterServerPartialReply(t *testing.T) {
+	s, err := net.ResolveUDPAddr("udp4", "127.0.0.1:28959")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := net.ListenUDP("udp4", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Only the first of two packets is sent.
+	go func() {
+		readBuffer := make([]byte, 64)
+		n, addr, err := c.ReadFromUDP(readBuffer)
+		if err != nil || n != 8 {
+			return
+		}
+		_, _ = c.WriteToUDP([]byte{
+			0x10, 0x6, 1, 2, readBuffer[4], readBuffer[5], 0x0, 0x66,
+			1, 'M', 0,
+			0, 1,
+			6, 12, 13, 14, 15, 97, 109,
+		}, addr)
+	}()
+
+	master := NewMasterServer("127.0.0.1:28959")
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	status, err := master.QueryStatus(ctx, WithQueryTimeout(5*time.Second))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("master.QueryStatus() returned after %s", elapsed)
+	}
+	var partial *PartialReplyError
+	if !errors.As(err, &partial) || partial.Received != 1 || partial.Total != 2 {
+		t.Fatalf("master.QueryStatus(): %v is not a partial reply of 1 of 2 packets", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("master.QueryStatus(): %v is not context.DeadlineExceeded", err)
+	}
+	if len(status.Servers) != 1 || status.Servers[0] != "12.13.14.15:28001" {
+		t.Errorf("status.Servers: %v", status.Servers)
+	}
+	if len(master.Servers()) != 0 {
+		t.Errorf("master.Servers(): %v kept a partial reply", master.Servers())
+	}
+}
@@ -0,0 +1,97 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MatchResult is the outcome of one match, as last seen before it ended.
+type MatchResult struct {
+	Address string
+	Mission string
+	// Start and End are the query times of the first and last status seen
+	// during the match.
+	Start time.Time
+	End   time.Time
+	// Roster holds the final team scores and the players on each team.
+	// Observers are left out.
+	Roster []RosterTeam
+}
+
+// DetectMatches finds the matches played in history, which must be one
+// server's statuses in QueryTime order.  A match ends when the mission
+// changes or a team's score goes down, which happens when scores are reset
+// for a new match.  Only matches that ended with players on at least two
+// teams are returned; the match still running at the end of history is not.
+func DetectMatches(history []GameServerStatus) (matches []MatchResult) {
+	start := 0
+	for i := 1; i < len(history); i++ {
+		if !matchEnded(&history[i-1], &history[i]) {
+			continue
+		}
+		last := &history[i-1]
+		roster := last.Roster(UnassignedDrop)
+		if populatedTeams(roster) >= 2 {
+			matches = append(matches, MatchResult{
+				Address: last.Address,
+				Mission: last.Mission,
+				Start:   history[start].QueryTime,
+				End:     last.QueryTime,
+				Roster:  roster,
+			})
+		}
+		start = i
+	}
+	return
+}
+
+// matchEnded reports whether next belongs to a different match than
+// previous.
+func matchEnded(previous, next *GameServerStatus) bool {
+	if previous.Mission != next.Mission {
+		return true
+	}
+	for i := range next.Teams {
+		if i >= len(previous.Teams) {
+			break
+		}
+		before, err := strconv.ParseFloat(strings.TrimSpace(previous.Teams[i].Score), 64)
+		if err != nil {
+			continue
+		}
+		after, err := strconv.ParseFloat(strings.TrimSpace(next.Teams[i].Score), 64)
+		if err != nil {
+			continue
+		}
+		if after < before {
+			return true
+		}
+	}
+	return false
+}
+
+func populatedTeams(roster []RosterTeam) (populated int) {
+	for _, team := range roster {
+		if len(team.Players) > 0 {
+			populated++
+		}
+	}
+	return
+}
@@ -0,0 +1,75 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetectMatches(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	players := []Player{{Name: "Alpha", Team: 0}, {Name: "Beta", Team: 1}, {Name: "Gamma", Team: 255}}
+	polls := []struct {
+		mission string
+		scores  [2]string
+		players []Player
+	}{
+		{"Raindance", [2]string{"0", "0"}, players},
+		{"Raindance", [2]string{"2", "1"}, players},
+		{"Raindance", [2]string{"5", "3"}, players},
+		// Scores reset for a rematch.
+		{"Raindance", [2]string{"0", "0"}, players},
+		{"Raindance", [2]string{"1", "4"}, players},
+		// Only one team populated when the mission changes.
+		{"Broadside", [2]string{"0", "0"}, players[0:1]},
+		{"Broadside", [2]string{"3", "0"}, players[0:1]},
+		{"Sanctuary", [2]string{"0", "0"}, players},
+		{"Sanctuary", [2]string{"1", "1"}, players},
+	}
+	var history []GameServerStatus
+	for i, poll := range polls {
+		history = append(history, GameServerStatus{
+			Address:   "1.1.1.1:28001",
+			Mission:   poll.mission,
+			QueryTime: start.Add(time.Duration(i) * 10 * time.Minute),
+			Teams:     []Team{{Name: "Blood Eagle", Score: poll.scores[0]}, {Name: "Diamond Sword", Score: poll.scores[1]}},
+			Players:   poll.players,
+		})
+	}
+
+	matches := DetectMatches(history)
+	if len(matches) != 2 {
+		t.Fatalf("DetectMatches(): %v", matches)
+	}
+	if matches[0].Mission != "Raindance" || !matches[0].Start.Equal(start) || !matches[0].End.Equal(start.Add(20*time.Minute)) {
+		t.Errorf("matches[0]: %v", matches[0])
+	}
+	if len(matches[0].Roster) != 2 || matches[0].Roster[0].Score != "5" || matches[0].Roster[1].Score != "3" {
+		t.Errorf("matches[0].Roster: %v", matches[0].Roster)
+	}
+	if len(matches[0].Roster[0].Players) != 1 || matches[0].Roster[0].Players[0].Name != "Alpha" {
+		t.Errorf("matches[0].Roster[0].Players: %v", matches[0].Roster[0].Players)
+	}
+	if matches[1].Roster[0].Score != "1" || matches[1].Roster[1].Score != "4" || !matches[1].Start.Equal(start.Add(30*time.Minute)) {
+		t.Errorf("matches[1]: %v", matches[1])
+	}
+
+	if DetectMatches(nil) != nil {
+		t.Error("DetectMatches(nil) != nil")
+	}
+}
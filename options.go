@@ -0,0 +1,120 @@
+//go:build go1.21
+
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import "time"
+
+// Option configures a component such as a GameServer or MasterServer.  The
+// With functions work with every component that has the matching setter,
+// and applying one to a component without it does not compile.  Options are
+// the canonical way to configure components; a QueryOption only changes
+// the single query it is passed to.
+type Option[T any] func(target T)
+
+// Configure applies options to target in order and returns it, so that it
+// can wrap a constructor:
+//
+//	game := Configure(NewGameServer(address), WithTimeout[*GameServer](time.Second))
+func Configure[T any](target T, options ...Option[T]) T {
+	for _, option := range options {
+		option(target)
+	}
+	return target
+}
+
+// WithTimeout calls SetTimeout.
+func WithTimeout[T interface{ SetTimeout(time.Duration) }](timeout time.Duration) Option[T] {
+	return func(target T) {
+		target.SetTimeout(timeout)
+	}
+}
+
+// WithLimits calls SetLimits.
+func WithLimits[T interface{ SetLimits(Limits) }](limits Limits) Option[T] {
+	return func(target T) {
+		target.SetLimits(limits)
+	}
+}
+
+// WithMinRefreshInterval calls SetMinRefreshInterval.
+func WithMinRefreshInterval[T interface{ SetMinRefreshInterval(time.Duration) }](interval time.Duration) Option[T] {
+	return func(target T) {
+		target.SetMinRefreshInterval(interval)
+	}
+}
+
+// WithFailureTTL calls SetFailureTTL.
+func WithFailureTTL[T interface{ SetFailureTTL(time.Duration) }](ttl time.Duration) Option[T] {
+	return func(target T) {
+		target.SetFailureTTL(ttl)
+	}
+}
+
+// WithClientID calls SetClientID.
+func WithClientID[T interface{ SetClientID(uint16) }](clientID uint16) Option[T] {
+	return func(target T) {
+		target.SetClientID(clientID)
+	}
+}
+
+// WithStringPool calls SetStringPool.
+func WithStringPool[T interface{ SetStringPool(*StringPool) }](pool *StringPool) Option[T] {
+	return func(target T) {
+		target.SetStringPool(pool)
+	}
+}
+
+// WithArenaParsing calls SetArenaParsing.
+func WithArenaParsing[T interface{ SetArenaParsing(bool) }](enabled bool) Option[T] {
+	return func(target T) {
+		target.SetArenaParsing(enabled)
+	}
+}
+
+// WithExtensionParser calls SetExtensionParser.
+func WithExtensionParser[T interface{ SetExtensionParser(ExtensionParser) }](parser ExtensionParser) Option[T] {
+	return func(target T) {
+		target.SetExtensionParser(parser)
+	}
+}
+
+// WithTrustParsedCounts calls SetTrustParsedCounts.
+func WithTrustParsedCounts[T interface{ SetTrustParsedCounts(bool) }](trust bool) Option[T] {
+	return func(target T) {
+		target.SetTrustParsedCounts(trust)
+	}
+}
+
+// WithBurst calls SetBurst.
+func WithBurst[T interface {
+	SetBurst(int, time.Duration, BurstPing)
+}](probes int, spacing time.Duration, ping BurstPing) Option[T] {
+	return func(target T) {
+		target.SetBurst(probes, spacing, ping)
+	}
+}
+
+// WithRetryPolicy calls SetRetries.
+func WithRetryPolicy[T interface {
+	SetRetries(int, time.Duration)
+}](retries int, backoff time.Duration) Option[T] {
+	return func(target T) {
+		target.SetRetries(retries, backoff)
+	}
+}
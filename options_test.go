@@ -0,0 +1,89 @@
+//go:build go1.21
+
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestConfigure(t *testing.T) {
+	limits := Limits{MaxServers: 10}
+	game := Configure(NewGameServer("127.0.0.1:28001"),
+		WithTimeout[*GameServer](time.Second),
+		WithLimits[*GameServer](limits),
+		WithFailureTTL[*GameServer](time.Minute),
+		WithRetryPolicy[*GameServer](2, time.Second),
+	)
+	if game.retries != 2 || game.backoff != time.Second {
+		t.Errorf("game.retries, game.backoff: %d, %s != 2, 1s", game.retries, game.backoff)
+	}
+	if game.Limits().MaxServers != 10 {
+		t.Errorf("game.Limits().MaxServers: %d != 10", game.Limits().MaxServers)
+	}
+
+	master := Configure(NewMasterServer("127.0.0.1:28000"),
+		WithTimeout[*MasterServer](2*time.Second),
+		WithLimits[*MasterServer](limits),
+		WithClientID[*MasterServer](42),
+	)
+	if master.Limits().MaxServers != 10 {
+		t.Errorf("master.Limits().MaxServers: %d != 10", master.Limits().MaxServers)
+	}
+	if master.ClientID() != 42 {
+		t.Errorf("master.ClientID(): %d != 42", master.ClientID())
+	}
+}
+
+func TestConfigureTimeout(t *testing.T) {
+	// The socket never answers, so queries last until they time out.
+	silent, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	address := silent.LocalAddr().String()
+
+	game := Configure(NewGameServer(address), WithTimeout[*GameServer](100*time.Millisecond))
+	start := time.Now()
+	if err = game.Query(0, ""); err == nil {
+		t.Fatal("game.Query(): expected a timeout")
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Errorf("game.Query() took %s, expected the 100ms timeout", elapsed)
+	}
+
+	master := Configure(NewMasterServer(address), WithTimeout[*MasterServer](100*time.Millisecond))
+	start = time.Now()
+	if err = master.QueryWith(context.Background()); err == nil {
+		t.Fatal("master.QueryWith(): expected a timeout")
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Errorf("master.QueryWith() took %s, expected the 100ms timeout", elapsed)
+	}
+
+	// A timeout given to the query overrides the configured one.
+	start = time.Now()
+	_ = master.QueryWith(context.Background(), WithQueryTimeout(10*time.Millisecond))
+	if elapsed := time.Since(start); elapsed > 90*time.Millisecond {
+		t.Errorf("master.QueryWith(WithQueryTimeout(10ms)) took %s", elapsed)
+	}
+}
@@ -0,0 +1,312 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// defaultMaxSessions is how many clients a UDPProxy relays for at once
+// unless told otherwise.
+const defaultMaxSessions = 1024
+
+// UDPProxy relays datagrams between clients and a backend server, so a
+// server on a private network can be queried through a public host.  Each
+// client gets its own socket to the backend, closed after it has been idle
+// for the idle timeout.  The number of clients served at once is capped, so
+// packets with spoofed sources cannot use up every file descriptor; packets
+// from further clients are dropped until a socket is free.
+type UDPProxy struct {
+	mutex       sync.Mutex
+	address     string
+	backend     string
+	rewrite     func(packet []byte) []byte
+	idleTimeout time.Duration
+	maxSessions int
+	dropped     uint64
+	closed      bool
+	conn        *net.UDPConn
+	sessions    map[string]*net.UDPConn
+}
+
+// SetRewrite makes the proxy pass every backend reply through rewrite before
+// relaying it, for example RewriteMasterAddresses.
+func (p *UDPProxy) SetRewrite(rewrite func(packet []byte) []byte) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.rewrite = rewrite
+}
+
+// SetIdleTimeout sets how long a client's backend socket is kept without
+// traffic.  The default is 30 seconds.
+func (p *UDPProxy) SetIdleTimeout(timeout time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.idleTimeout = timeout
+}
+
+// SetMaxSessions sets how many clients the proxy relays for at once, each
+// with its own backend socket.  The default is 1024.
+func (p *UDPProxy) SetMaxSessions(sessions int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.maxSessions = sessions
+}
+
+// Dropped returns how many packets were dropped because no backend socket
+// could be opened for their client.
+func (p *UDPProxy) Dropped() (dropped uint64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.dropped
+}
+
+func (p *UDPProxy) ListenAndServe() (err error) {
+	localAddr, err := net.ResolveUDPAddr("udp", p.address)
+	if err != nil {
+		return
+	}
+
+	c, err := net.ListenUDP("udp", localAddr)
+	if err != nil {
+		return
+	}
+
+	return p.Serve(c)
+}
+
+// Serve relays datagrams read from c until c is closed or Close is called.
+func (p *UDPProxy) Serve(c *net.UDPConn) (err error) {
+	backendAddr, err := net.ResolveUDPAddr("udp", p.backend)
+	if err != nil {
+		_ = c.Close()
+		return
+	}
+
+	// Close may run before c is registered; it must still stop Serve.
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		_ = c.Close()
+		return
+	}
+	p.conn = c
+	p.sessions = make(map[string]*net.UDPConn)
+	p.mutex.Unlock()
+
+	defer func() {
+		_ = c.Close()
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		for _, session := range p.sessions {
+			_ = session.Close()
+		}
+		p.sessions = nil
+	}()
+
+	readBuffer := make([]byte, 2048)
+	var (
+		n       int
+		addr    *net.UDPAddr
+		session *net.UDPConn
+	)
+	for {
+		n, addr, err = c.ReadFromUDP(readBuffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				err = nil
+			}
+			return
+		}
+
+		// A client that cannot be given a session, or a failed send for
+		// one, should not stop the proxy.
+		session, err = p.session(c, addr, backendAddr)
+		if err != nil {
+			err = nil
+			continue
+		}
+		_, _ = session.Write(readBuffer[0:n])
+	}
+}
+
+// session returns the backend socket for client, creating it and the
+// goroutine relaying its replies if needed.  It fails, counting the packet
+// as dropped, if the session cap is reached or the socket cannot be opened.
+func (p *UDPProxy) session(c *net.UDPConn, client, backendAddr *net.UDPAddr) (session *net.UDPConn, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	key := client.String()
+	if session = p.sessions[key]; session != nil {
+		return
+	}
+
+	maxSessions := p.maxSessions
+	if maxSessions <= 0 {
+		maxSessions = defaultMaxSessions
+	}
+	if len(p.sessions) >= maxSessions {
+		p.dropped++
+		return nil, fmt.Errorf("t1net.UDPProxy.session: Session limit reached: %d", maxSessions)
+	}
+	session, err = net.DialUDP("udp", nil, backendAddr)
+	if err != nil {
+		p.dropped++
+		return
+	}
+	p.sessions[key] = session
+
+	idleTimeout := p.idleTimeout
+	if idleTimeout == 0 {
+		idleTimeout = 30 * time.Second
+	}
+
+	go func() {
+		defer func() {
+			_ = session.Close()
+			p.mutex.Lock()
+			defer p.mutex.Unlock()
+			if p.sessions[key] == session {
+				delete(p.sessions, key)
+			}
+		}()
+
+		readBuffer := make([]byte, 2048)
+		for {
+			if session.SetReadDeadline(time.Now().Add(idleTimeout)) != nil {
+				return
+			}
+			n, err := session.Read(readBuffer)
+			if err != nil {
+				var netErr net.Error
+				if errors.As(err, &netErr) && !netErr.Timeout() {
+					// ICMP errors such as connection refused are not fatal.
+					continue
+				}
+				return
+			}
+
+			p.mutex.Lock()
+			rewrite := p.rewrite
+			p.mutex.Unlock()
+
+			packet := readBuffer[0:n]
+			if rewrite != nil {
+				packet = rewrite(packet)
+			}
+			if _, err = c.WriteToUDP(packet, client); err != nil && errors.Is(err, net.ErrClosed) {
+				return
+			}
+		}
+	}()
+
+	return
+}
+
+// Close stops Serve and ListenAndServe, even if they have not started
+// reading yet.  A closed proxy cannot serve again.
+func (p *UDPProxy) Close() (err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.closed = true
+	if p.conn == nil {
+		return
+	}
+	return p.conn.Close()
+}
+
+func NewUDPProxy(address string, backend string) *UDPProxy {
+	return &UDPProxy{address: address, backend: backend}
+}
+
+// RewriteMasterAddresses returns a rewrite function for UDPProxy that
+// replaces server addresses in master server replies according to mapping,
+// which maps "ip:port" as listed by the master to the address to advertise
+// instead.  Packets that are not master replies are returned unchanged.
+func RewriteMasterAddresses(mapping map[string]string) (rewrite func(packet []byte) []byte, err error) {
+	replacements := make(map[[6]byte][6]byte, len(mapping))
+	for from, to := range mapping {
+		var fromEntry, toEntry [6]byte
+		if fromEntry, err = addressEntry(from); err != nil {
+			return
+		}
+		if toEntry, err = addressEntry(to); err != nil {
+			return
+		}
+		replacements[fromEntry] = toEntry
+	}
+
+	rewrite = func(packet []byte) []byte {
+		// 0x10 0x06 = master server list reply
+		if len(packet) < 8 || packet[0] != 0x10 || packet[1] != 0x06 {
+			return packet
+		}
+
+		reader := bytes.NewReader(packet[8:])
+		for i := 0; i < 2; i++ {
+			if _, err := ReadPascalString(reader); err != nil {
+				return packet
+			}
+		}
+		var serverCount uint16
+		if binary.Read(reader, binary.BigEndian, &serverCount) != nil {
+			return packet
+		}
+
+		rewritten := make([]byte, len(packet))
+		copy(rewritten, packet)
+		offset := len(packet) - reader.Len()
+		for i := uint16(0); i < serverCount && offset+7 <= len(rewritten); i++ {
+			var entry [6]byte
+			copy(entry[:], rewritten[offset+1:offset+7])
+			if to, ok := replacements[entry]; ok && rewritten[offset] == 6 {
+				copy(rewritten[offset+1:offset+7], to[:])
+			}
+			offset += 7
+		}
+		return rewritten
+	}
+	return
+}
+
+// addressEntry encodes an "ip:port" address as it appears in a master reply,
+// without the length byte.
+func addressEntry(address string) (entry [6]byte, err error) {
+	addr, err := net.ResolveUDPAddr("udp4", address)
+	if err != nil {
+		return
+	}
+	ip := addr.IP.To4()
+	if ip == nil {
+		err = fmt.Errorf("t1net.RewriteMasterAddresses: %s is not an IPv4 address", address)
+		return
+	}
+
+	var buffer bytes.Buffer
+	if err = WriteAddressPort(&buffer, ip, uint16(addr.Port)); err != nil {
+		return
+	}
+	copy(entry[:], buffer.Bytes()[1:])
+	return
+}
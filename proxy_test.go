@@ -0,0 +1,175 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUDPProxy(t *testing.T) {
+	startResponder(t, "127.0.0.1:28989", GameServerStatus{
+		Name:    "Private Server",
+		Game:    "Tribes",
+		Version: "1.11",
+		Mission: "Broadside",
+	})
+
+	proxy := NewUDPProxy("127.0.0.1:28988", "127.0.0.1:28989")
+	s, err := net.ResolveUDPAddr("udp4", "127.0.0.1:28988")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := net.ListenUDP("udp4", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- proxy.Serve(c)
+	}()
+
+	game := NewGameServer("127.0.0.1:28988")
+	err = game.Query(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Name() != "Private Server" {
+		t.Errorf("game.Name(): %s != Private Server", game.Name())
+	}
+	if game.Mission() != "Broadside" {
+		t.Errorf("game.Mission(): %s != Broadside", game.Mission())
+	}
+
+	err = proxy.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRewriteMasterAddresses(t *testing.T) {
+	rewrite, err := RewriteMasterAddresses(map[string]string{
+		"10.0.0.5:28001": "22.23.24.25:28005",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packet := []byte{
+		0x10, 0x6, 1, 1, 0x12, 0x34, 0x0, 0x66,
+		6, 'M', 'a', 's', 't', 'e', 'r',
+		4, 'M', 'O', 'T', 'D',
+		0, 2,
+		6, 10, 0, 0, 5, 97, 109,
+		6, 12, 13, 14, 15, 97, 109,
+	}
+	original := append([]byte(nil), packet...)
+
+	master := NewMasterServer("127.0.0.1:28000")
+	err = master.LoadReply(rewrite(packet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers := master.Servers()
+	if len(servers) != 2 || servers[0] != "22.23.24.25:28005" || servers[1] != "12.13.14.15:28001" {
+		t.Errorf("master.Servers(): %v", servers)
+	}
+	if !bytes.Equal(packet, original) {
+		t.Error("rewrite modified its input")
+	}
+
+	other := []byte{0x10, 0x4, 0x1, 0x0}
+	if !bytes.Equal(rewrite(other), other) {
+		t.Error("rewrite changed a packet that is not a master reply")
+	}
+
+	_, err = RewriteMasterAddresses(map[string]string{"bogus": "1.2.3.4:28001"})
+	if err == nil {
+		t.Error("expected an error for an invalid address")
+	}
+}
+
+func TestUDPProxyMaxSessions(t *testing.T) {
+	startResponder(t, "127.0.0.1:28952", GameServerStatus{Name: "Capped", Game: "Tribes", Version: "1.11"})
+
+	proxy := NewUDPProxy("127.0.0.1:28951", "127.0.0.1:28952")
+	proxy.SetMaxSessions(1)
+	proxy.SetIdleTimeout(200 * time.Millisecond)
+	c, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 28951})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- proxy.Serve(c)
+	}()
+
+	// Every query is sent from a new socket, so each is a new client.
+	if err := NewGameServer("127.0.0.1:28951").Query(time.Second, ""); err != nil {
+		t.Fatalf("Query(): %v", err)
+	}
+	if err := NewGameServer("127.0.0.1:28951").Query(100*time.Millisecond, ""); err == nil {
+		t.Error("Query(): expected a timeout past the session limit")
+	}
+	if proxy.Dropped() != 1 {
+		t.Errorf("proxy.Dropped(): %d != 1", proxy.Dropped())
+	}
+
+	// The proxy keeps serving once the first session has gone idle.
+	time.Sleep(300 * time.Millisecond)
+	if err := NewGameServer("127.0.0.1:28951").Query(time.Second, ""); err != nil {
+		t.Errorf("Query() after the idle timeout: %v", err)
+	}
+
+	if err := proxy.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Serve(): %v", err)
+	}
+}
+
+func TestUDPProxyCloseBeforeServe(t *testing.T) {
+	c, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := NewUDPProxy(c.LocalAddr().String(), "127.0.0.1:28952")
+	if err = proxy.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- proxy.Serve(c)
+	}()
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Errorf("Serve(): %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Serve() after Close() did not return")
+		_ = c.Close()
+	}
+}
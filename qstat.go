@@ -0,0 +1,108 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// QStatType is the server type qstat reports Tribes servers as.
+const QStatType = "TBS"
+
+// WriteQStat writes statuses in qstat's default text format: a header, then
+// one line per server with its address, player count, mission, ping,
+// retries and name.  With players set, each server line is followed by one
+// tab indented line per player with their score, ping, team and name.
+// Retries are always reported as zero.
+func WriteQStat(w io.Writer, statuses []GameServerStatus, players bool) (err error) {
+	_, err = fmt.Fprintf(w, "%-22s %7s %12s %13s  %s\n", "ADDRESS", "PLAYERS", "MAP", "RESPONSE TIME", "NAME")
+	if err != nil {
+		return
+	}
+	for i := range statuses {
+		s := &statuses[i]
+		_, err = fmt.Fprintf(w, "%-22s %3d/%-3d %12s %9d / 0  %s\n", s.Address, len(s.Players), s.MaxPlayers, s.Mission, s.Ping.Milliseconds(), s.Name)
+		if err != nil {
+			return
+		}
+		if !players {
+			continue
+		}
+		for _, player := range s.Players {
+			team, _ := s.PlayerTeam(player, UnassignedObservers)
+			_, err = fmt.Fprintf(w, "\t%5s score %4dms %s  %s\n", player.Score, player.Ping, team.Name, player.Name)
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// WriteQStatRaw writes statuses in qstat's -raw format, with fields
+// separated by delimiter: QStatType, address, name, mission, max players,
+// players, ping and retries.  With players set, each server line is followed
+// by one line per player with their name, score, team and ping, and a blank
+// line.  Delimiters in names are replaced with spaces so the fields can
+// always be split.  An empty delimiter is taken as a comma.
+func WriteQStatRaw(w io.Writer, delimiter string, statuses []GameServerStatus, players bool) (err error) {
+	if len(delimiter) == 0 {
+		delimiter = ","
+	}
+	field := func(value string) string {
+		return strings.ReplaceAll(value, delimiter, " ")
+	}
+	for i := range statuses {
+		s := &statuses[i]
+		_, err = io.WriteString(w, strings.Join([]string{
+			QStatType,
+			s.Address,
+			field(s.Name),
+			field(s.Mission),
+			strconv.Itoa(int(s.MaxPlayers)),
+			strconv.Itoa(len(s.Players)),
+			strconv.FormatInt(s.Ping.Milliseconds(), 10),
+			"0",
+		}, delimiter)+"\n")
+		if err != nil {
+			return
+		}
+		if !players {
+			continue
+		}
+		for _, player := range s.Players {
+			team, _ := s.PlayerTeam(player, UnassignedObservers)
+			_, err = io.WriteString(w, strings.Join([]string{
+				field(player.Name),
+				field(player.Score),
+				field(team.Name),
+				strconv.Itoa(int(player.Ping)),
+			}, delimiter)+"\n")
+			if err != nil {
+				return
+			}
+		}
+		_, err = io.WriteString(w, "\n")
+		if err != nil {
+			return
+		}
+	}
+	return
+}
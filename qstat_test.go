@@ -0,0 +1,77 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+var qstatStatuses = []GameServerStatus{
+	{
+		Address:    "1.2.3.4:28001",
+		Ping:       42 * time.Millisecond,
+		Name:       "Alpha, Bravo",
+		Mission:    "Raindance",
+		MaxPlayers: 32,
+		Teams:      []Team{{Name: "Blood Eagle"}},
+		Players: []Player{
+			{Name: "One", Score: "10", Ping: 50, Team: 0},
+			{Name: "Two", Score: "3", Ping: 80, Team: 255},
+		},
+	},
+}
+
+func TestWriteQStat(t *testing.T) {
+	var buffer bytes.Buffer
+	err := WriteQStat(&buffer, qstatStatuses, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("WriteQStat(): %d lines != 4:\n%s", len(lines), buffer.String())
+	}
+	if !strings.HasPrefix(lines[0], "ADDRESS") {
+		t.Errorf("header: %q", lines[0])
+	}
+	for _, field := range []string{"1.2.3.4:28001", "2/32", "Raindance", "42 / 0", "Alpha, Bravo"} {
+		if !strings.Contains(lines[1], field) {
+			t.Errorf("server line %q is missing %q", lines[1], field)
+		}
+	}
+	if !strings.Contains(lines[3], ObserversTeamName) || !strings.HasSuffix(lines[3], "Two") {
+		t.Errorf("player line: %q", lines[3])
+	}
+}
+
+func TestWriteQStatRaw(t *testing.T) {
+	var buffer bytes.Buffer
+	err := WriteQStatRaw(&buffer, ",", qstatStatuses, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "TBS,1.2.3.4:28001,Alpha  Bravo,Raindance,32,2,42,0\n" +
+		"One,10,Blood Eagle,50\n" +
+		"Two,3,Observers,80\n" +
+		"\n"
+	if buffer.String() != expected {
+		t.Errorf("WriteQStatRaw(): %q != %q", buffer.String(), expected)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"sync"
+	"time"
+)
+
+// QuarantinedReply is a reply packet that failed to parse, kept by a
+// Quarantine as a sample of what servers in the wild send.
+type QuarantinedReply struct {
+	// Kind is "game" or "master".
+	Kind string
+	// Address is the server's address as given.
+	Address string
+	Time    time.Time
+	// Packet is a copy of the raw reply.
+	Packet []byte
+	// Error is why parsing failed.
+	Error string
+}
+
+// Quarantine keeps the most recent replies that failed to parse, up to a
+// fixed number, so operators can collect samples of protocol variations the
+// parser does not support yet.  Pass one to queries with WithQuarantine.  A
+// QuarantinedReply holds only exported fields, so the samples can be saved
+// with encoding/json.  It is safe for concurrent use.
+type Quarantine struct {
+	mutex    sync.Mutex
+	capacity int
+	replies  []QuarantinedReply
+	// next is the index in replies the next reply is stored at once it is
+	// full.
+	next    int
+	evicted uint64
+}
+
+// NewQuarantine returns a Quarantine holding up to capacity replies, at
+// least one.  Once full, each new reply evicts the oldest.
+func NewQuarantine(capacity int) *Quarantine {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Quarantine{capacity: capacity}
+}
+
+// Add stores reply, evicting the oldest reply if the quarantine is full.
+func (q *Quarantine) Add(reply QuarantinedReply) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if len(q.replies) < q.capacity {
+		q.replies = append(q.replies, reply)
+		return
+	}
+	q.replies[q.next] = reply
+	q.next = (q.next + 1) % q.capacity
+	q.evicted++
+}
+
+// add stores packet, which failed to parse with err, unless q is nil or err
+// is.
+func (q *Quarantine) add(kind string, address string, packet []byte, err error) {
+	if q == nil || err == nil {
+		return
+	}
+	q.Add(QuarantinedReply{
+		Kind:    kind,
+		Address: address,
+		Time:    time.Now(),
+		Packet:  append([]byte(nil), packet...),
+		Error:   err.Error(),
+	})
+}
+
+// Replies returns the stored replies, oldest first.
+func (q *Quarantine) Replies() (replies []QuarantinedReply) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	replies = make([]QuarantinedReply, 0, len(q.replies))
+	replies = append(replies, q.replies[q.next:]...)
+	replies = append(replies, q.replies[:q.next]...)
+	return
+}
+
+// Len returns how many replies are stored.
+func (q *Quarantine) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.replies)
+}
+
+// Evicted returns how many replies have been evicted to make room for newer
+// ones.
+func (q *Quarantine) Evicted() (evicted uint64) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.evicted
+}
+
+// Clear removes every stored reply.
+func (q *Quarantine) Clear() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.replies, q.next = nil, 0
+}
@@ -0,0 +1,93 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestQuarantine(t *testing.T) {
+	q := NewQuarantine(2)
+	for _, address := range []string{"a", "b", "c"} {
+		q.Add(QuarantinedReply{Address: address})
+	}
+	replies := q.Replies()
+	if len(replies) != 2 || replies[0].Address != "b" || replies[1].Address != "c" {
+		t.Errorf("q.Replies(): %+v", replies)
+	}
+	if q.Len() != 2 || q.Evicted() != 1 {
+		t.Errorf("q.Len(): %d != 2, q.Evicted(): %d != 1", q.Len(), q.Evicted())
+	}
+	q.Clear()
+	if q.Len() != 0 || len(q.Replies()) != 0 {
+		t.Errorf("q.Len() after Clear(): %d != 0", q.Len())
+	}
+}
+
+func TestQueryQuarantine(t *testing.T) {
+	var valid bytes.Buffer
+	status := GameServerStatus{Name: "Valid"}
+	dial := pipeDialer(func(request []byte) [][]byte {
+		valid.Reset()
+		if err := status.WriteReply(&valid, binary.BigEndian.Uint16(request[1:3])); err != nil {
+			t.Error(err)
+		}
+		return [][]byte{valid.Bytes()}
+	})
+	q := NewQuarantine(4)
+	g := NewGameServer("192.0.2.1:28001")
+	err := g.QueryWith(context.Background(), WithDialer(dial), WithQuarantine(q), WithQueryTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("QueryWith(): %v", err)
+	}
+	if q.Len() != 0 {
+		t.Errorf("q.Len(): %d != 0 after a valid reply", q.Len())
+	}
+
+	garbage := []byte{0x63, 0, 0, 0x62, 200, 'x'}
+	dial = pipeDialer(func(request []byte) [][]byte {
+		return [][]byte{garbage}
+	})
+	err = g.QueryWith(context.Background(), WithDialer(dial), WithQuarantine(q), WithQueryTimeout(time.Second))
+	if err == nil {
+		t.Fatal("QueryWith(): expected an error for a garbage reply")
+	}
+	replies := q.Replies()
+	if len(replies) != 1 {
+		t.Fatalf("len(q.Replies()): %d != 1", len(replies))
+	}
+	if replies[0].Kind != "game" || replies[0].Address != "192.0.2.1:28001" || !bytes.Equal(replies[0].Packet, garbage) || replies[0].Error != err.Error() {
+		t.Errorf("q.Replies()[0]: %+v", replies[0])
+	}
+
+	m := NewMasterServer("192.0.2.1:28000")
+	dial = pipeDialer(func(request []byte) [][]byte {
+		return [][]byte{garbage}
+	})
+	err = m.QueryWith(context.Background(), WithDialer(dial), WithQuarantine(q), WithQueryTimeout(time.Second))
+	if err == nil {
+		t.Fatal("m.QueryWith(): expected an error for a garbage reply")
+	}
+	replies = q.Replies()
+	if len(replies) != 2 || replies[1].Kind != "master" || !bytes.Equal(replies[1].Packet, garbage) {
+		t.Errorf("q.Replies(): %+v", replies)
+	}
+}
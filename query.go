@@ -0,0 +1,313 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// defaultQueryTimeout is the timeout used when none is set.
+const defaultQueryTimeout = 5 * time.Second
+
+// QueryOption changes how a single query made with QueryWith is sent.  It
+// complements Option, the canonical way to configure a component: an Option
+// such as WithTimeout calls a setter and lasts for every later query, while
+// a QueryOption applies to the one call it is passed to and overrides the
+// component's setting for it, as WithQueryTimeout overrides WithTimeout and
+// WithRetries overrides WithRetryPolicy.  Settings that only make sense per
+// call, such as WithConn or WithClient, exist only as QueryOptions.
+// QueryOptions need no generics, so they work with every Go version the
+// module supports.
+type QueryOption func(options *queryOptions)
+
+type queryOptions struct {
+	timeout      time.Duration
+	localAddress string
+	network      string
+	retries      int
+	backoff      time.Duration
+	conn         net.PacketConn
+	dial         DialFunc
+	client       *Client
+	concurrency  int
+	rate         float64
+	simulation   *Simulation
+	packetHook   PacketHook
+	resolver     Resolver
+	quarantine   *Quarantine
+}
+
+// WithQueryTimeout sets how long to wait for a reply.  Zero, the default,
+// uses the timeout set with SetTimeout.
+func WithQueryTimeout(timeout time.Duration) QueryOption {
+	return func(options *queryOptions) {
+		if timeout != 0 {
+			options.timeout = timeout
+		}
+	}
+}
+
+// WithLocalAddr sends the query from localAddress.  It is ignored when
+// WithConn or WithDialer is used.
+func WithLocalAddr(localAddress string) QueryOption {
+	return func(options *queryOptions) {
+		options.localAddress = localAddress
+	}
+}
+
+// WithNetwork restricts the query to network: "udp4" for IPv4 only, as the
+// master server wire format can only list IPv4 servers, or "udp6" for IPv6
+// only.  The default, "udp", queries both, trying the addresses the
+// server's host resolves to in the order the resolver returns them.
+func WithNetwork(network string) QueryOption {
+	return func(options *queryOptions) {
+		options.network = network
+	}
+}
+
+// WithResolver looks up the server's host with resolver, such as a
+// *net.Resolver using a particular DNS server or a CachingResolver, instead
+// of net.DefaultResolver.
+func WithResolver(resolver Resolver) QueryOption {
+	return func(options *queryOptions) {
+		options.resolver = resolver
+	}
+}
+
+// WithRetries sends the query again, with a fresh key, up to retries more
+// times while it times out.  It overrides the retries set with SetRetries.
+func WithRetries(retries int) QueryOption {
+	return func(options *queryOptions) {
+		options.retries = retries
+	}
+}
+
+// WithBackoff waits backoff before the first retry, doubling the wait before
+// each one after it.  It overrides the backoff set with SetRetries.
+func WithBackoff(backoff time.Duration) QueryOption {
+	return func(options *queryOptions) {
+		options.backoff = backoff
+	}
+}
+
+// WithConn sends the query over c instead of a socket of its own, so many
+// queries can share one socket.  c may be a connected or unconnected
+// *net.UDPConn, or any net.PacketConn such as one tunnelling the packets
+// elsewhere.  Replies from other addresses are skipped and c is left open.
+// Only the first address the server's host resolves to that c can reach is
+// queried.
+func WithConn(c net.PacketConn) QueryOption {
+	return func(options *queryOptions) {
+		options.conn = c
+	}
+}
+
+// DialFunc opens a connection to address on network, "udp", "udp4" or
+// "udp6".  net.Dialer's DialContext is one.
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// WithDialer opens the query's sockets with dial instead of net.DialUDP,
+// one per address tried, so they can be routed through a tunnel or
+// replaced in tests.  Every packet read from a connection dial returns is
+// taken to come from the address it was dialed to.  It overrides
+// WithLocalAddr and is ignored when WithConn or WithClient is used.
+func WithDialer(dial DialFunc) QueryOption {
+	return func(options *queryOptions) {
+		options.dial = dial
+	}
+}
+
+// WithClient sends a game server query through client, which shares one
+// socket between many queries.  It overrides WithConn and WithLocalAddr.
+// Only the first address the server's host resolves to that the client's
+// socket can reach is queried, and SetBurst is ignored.  Master server
+// queries do not support it and use their own socket.
+func WithClient(client *Client) QueryOption {
+	return func(options *queryOptions) {
+		options.client = client
+	}
+}
+
+// WithConcurrency sets how many servers QueryAll queries at once.  The
+// default is 16.  Single queries ignore it.
+func WithConcurrency(concurrency int) QueryOption {
+	return func(options *queryOptions) {
+		options.concurrency = concurrency
+	}
+}
+
+// WithRate limits QueryAll to starting perSecond queries a second.  Zero,
+// the default, does not limit the rate.  Single queries ignore it.
+func WithRate(perSecond float64) QueryOption {
+	return func(options *queryOptions) {
+		options.rate = perSecond
+	}
+}
+
+// WithSimulation answers queries from s instead of sending them, for
+// development without network access.  It overrides WithClient, WithConn
+// and WithLocalAddr.
+func WithSimulation(s *Simulation) QueryOption {
+	return func(options *queryOptions) {
+		options.simulation = s
+	}
+}
+
+// WithPacketHook passes every packet the query sends or receives to hook,
+// along with the query's context, whether or not tracing is enabled.
+func WithPacketHook(hook PacketHook) QueryOption {
+	return func(options *queryOptions) {
+		options.packetHook = hook
+	}
+}
+
+// WithQuarantine stores replies that fail to parse in q.  Replies with the
+// wrong key are stored too, as they are rejected by the parser.
+func WithQuarantine(q *Quarantine) QueryOption {
+	return func(options *queryOptions) {
+		options.quarantine = q
+	}
+}
+
+// newQueryOptions applies options to defaults, which hold the server's
+// configuration.
+func newQueryOptions(defaults queryOptions, options []QueryOption) (o queryOptions) {
+	o = defaults
+	for _, option := range options {
+		option(&o)
+	}
+	if o.timeout == 0 {
+		o.timeout = defaultQueryTimeout
+	}
+	if o.network == "" {
+		o.network = "udp"
+	}
+	return
+}
+
+// maxBackoff caps the wait between retries.
+const maxBackoff = time.Minute
+
+// retry calls query until it succeeds, fails with something other than a
+// timeout, ctx is done or retries have run out.  It waits backoff before the
+// first retry and twice as long before each one after it, up to maxBackoff.
+func retry(ctx context.Context, retries int, backoff time.Duration, query func() error) (err error) {
+	for attempt := 0; ; attempt++ {
+		err = query()
+		var netErr net.Error
+		if err == nil || attempt >= retries || !errors.As(err, &netErr) || !netErr.Timeout() || contextDone(ctx) != nil {
+			return
+		}
+		if backoff > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+	}
+}
+
+// dialer returns the DialFunc set with WithDialer, or one opening UDP
+// sockets bound to the address set with WithLocalAddr.
+func (o queryOptions) dialer() (dial DialFunc, err error) {
+	if o.dial != nil {
+		return o.dial, nil
+	}
+	dialer := &net.Dialer{}
+	if len(o.localAddress) != 0 {
+		dialer.LocalAddr, err = net.ResolveUDPAddr(o.network, o.localAddress)
+		if err != nil {
+			return
+		}
+	}
+	return dialer.DialContext, nil
+}
+
+// packetConn adapts a connection returned by a DialFunc to net.PacketConn.
+// Every packet read is reported as coming from remoteAddr, the address the
+// connection was dialed to.
+type packetConn struct {
+	net.Conn
+	remoteAddr *net.UDPAddr
+}
+
+func (c packetConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	n, err = c.Read(p)
+	return n, c.remoteAddr, err
+}
+
+func (c packetConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	return c.Write(p)
+}
+
+// sameAddress reports whether addr, the source of a packet, is remoteAddr.
+func sameAddress(addr net.Addr, remoteAddr *net.UDPAddr) bool {
+	if udpAddr, ok := addr.(*net.UDPAddr); ok {
+		return udpAddr.IP.Equal(remoteAddr.IP) && udpAddr.Port == remoteAddr.Port
+	}
+	return addr != nil && addr.String() == remoteAddr.String()
+}
+
+// sendTo writes packet to remoteAddr over c, which may be connected to it.
+func sendTo(c net.PacketConn, remoteAddr *net.UDPAddr, packet []byte) (err error) {
+	if conn, ok := c.(net.Conn); ok && conn.RemoteAddr() != nil {
+		_, err = conn.Write(packet)
+		return
+	}
+	_, err = c.WriteTo(packet, remoteAddr)
+	return
+}
+
+// exchange sends request to remoteAddr over c and returns the first reply
+// from remoteAddr and its round trip time.  Packets from other addresses
+// are skipped.  Packets are recorded in trace, which may be nil.
+func exchange(c net.PacketConn, remoteAddr *net.UDPAddr, request []byte, timeout time.Duration, trace *QueryTrace) (reply []byte, ping time.Duration, err error) {
+	trace.packet(true, remoteAddr, request)
+	sent := time.Now()
+	err = sendTo(c, remoteAddr, request)
+	if err != nil {
+		return
+	}
+	err = c.SetReadDeadline(sent.Add(timeout))
+	if err != nil {
+		return
+	}
+
+	readBuffer := make([]byte, 2048)
+	for {
+		n, addr, err := c.ReadFrom(readBuffer)
+		if err != nil {
+			return nil, 0, err
+		}
+		if sameAddress(addr, remoteAddr) {
+			// The ping is taken before the packet hook runs.
+			ping := time.Since(sent)
+			trace.packet(false, addr, readBuffer[0:n])
+			return readBuffer[0:n], ping, nil
+		}
+	}
+}
@@ -0,0 +1,416 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestQueryWithConn(t *testing.T) {
+	var responders []*QueryResponder
+	for _, address := range []string{"127.0.0.1:28982", "127.0.0.1:28981"} {
+		responders = append(responders, startResponder(t, address, GameServerStatus{Name: address, Game: "Tribes", Version: "1.11"}))
+	}
+
+	c, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for _, address := range []string{"127.0.0.1:28982", "127.0.0.1:28981"} {
+		game := NewGameServer(address)
+		err = game.QueryWith(context.Background(), WithConn(c), WithQueryTimeout(time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if game.Name() != address {
+			t.Errorf("game.Name(): %s != %s", game.Name(), address)
+		}
+	}
+
+	// The shared socket is left open.
+	_, err = c.WriteToUDP([]byte("\\echo\\"), &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 28982})
+	if err != nil {
+		t.Errorf("c.WriteToUDP(): %v", err)
+	}
+}
+
+func TestQueryWithRetries(t *testing.T) {
+	s, err := net.ResolveUDPAddr("udp4", "127.0.0.1:28980")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := net.ListenUDP("udp4", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// The first request is dropped, the second answered.
+	go func() {
+		readBuffer := make([]byte, 64)
+		for i := 0; i < 2; i++ {
+			n, addr, err := c.ReadFromUDP(readBuffer)
+			if err != nil {
+				return
+			}
+			if i == 0 || n != 3 {
+				continue
+			}
+			status := GameServerStatus{Name: "Second Try", Game: "Tribes", Version: "1.11"}
+			var buffer bytes.Buffer
+			if status.WriteReply(&buffer, binary.BigEndian.Uint16(readBuffer[1:3])) == nil {
+				_, _ = c.WriteToUDP(buffer.Bytes(), addr)
+			}
+		}
+	}()
+
+	game := NewGameServer("127.0.0.1:28980")
+	err = game.QueryWith(context.Background(), WithQueryTimeout(100*time.Millisecond), WithRetries(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Name() != "Second Try" {
+		t.Errorf("game.Name(): %s != Second Try", game.Name())
+	}
+}
+
+func TestGameServerSetRetries(t *testing.T) {
+	s, err := net.ResolveUDPAddr("udp4", "127.0.0.1:28979")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := net.ListenUDP("udp4", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Every request is dropped; their keys are collected.
+	keys := make(chan uint16, 8)
+	go func() {
+		readBuffer := make([]byte, 64)
+		for {
+			n, _, err := c.ReadFromUDP(readBuffer)
+			if err != nil {
+				close(keys)
+				return
+			}
+			if n == 3 {
+				keys <- binary.BigEndian.Uint16(readBuffer[1:3])
+			}
+		}
+	}()
+
+	game := NewGameServer("127.0.0.1:28979")
+	game.SetRetries(2, 50*time.Millisecond)
+	start := time.Now()
+	err = game.Query(20*time.Millisecond, "")
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("game.Query(): %v is not a timeout", err)
+	}
+	// Three 20ms attempts separated by 50ms and 100ms waits.
+	if elapsed := time.Since(start); elapsed < 210*time.Millisecond {
+		t.Errorf("game.Query() returned after %s, expected at least 210ms", elapsed)
+	}
+
+	_ = c.Close()
+	seen := make(map[uint16]bool)
+	for key := range keys {
+		seen[key] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("responder saw %d distinct keys, expected 3", len(seen))
+	}
+}
+
+func TestGameServerQueryStatus(t *testing.T) {
+	responder := startResponder(t, "127.0.0.1:28978", GameServerStatus{
+		Name:    "Snapshot",
+		Game:    "Tribes",
+		Version: "1.11",
+		Players: []Player{{Name: "Alpha", Score: "5"}},
+	})
+
+	game := NewGameServer("127.0.0.1:28978")
+	first, err := game.QueryStatus(context.Background(), WithQueryTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Name != "Snapshot" || len(first.Players) != 1 || first.QueryTime.IsZero() {
+		t.Errorf("game.QueryStatus(): %+v", first)
+	}
+
+	responder.SetStatus(GameServerStatus{Name: "Renamed", Game: "Tribes", Version: "1.11"})
+	second, err := game.QueryStatus(context.Background(), WithQueryTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Name != "Snapshot" || first.Players[0].Name != "Alpha" {
+		t.Errorf("first snapshot changed: %+v", first)
+	}
+	if second.Name != "Renamed" || len(second.Players) != 0 {
+		t.Errorf("game.QueryStatus(): %+v", second)
+	}
+}
+
+func TestGameServerQueryDoesNotBlockReaders(t *testing.T) {
+	s, err := net.ResolveUDPAddr("udp4", "127.0.0.1:28977")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := net.ListenUDP("udp4", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	status := GameServerStatus{Name: "Previous", Game: "Tribes", Version: "1.11"}
+	var buffer bytes.Buffer
+	err = status.WriteReply(&buffer, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGameServer("127.0.0.1:28977")
+	err = game.LoadReply(buffer.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- game.Query(300*time.Millisecond, "")
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	name := game.Name()
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("game.Name() blocked for %s during a query", elapsed)
+	}
+	if name != "Previous" {
+		t.Errorf("game.Name(): %s != Previous", name)
+	}
+
+	if err = <-done; err == nil {
+		t.Fatal("expected the unanswered query to fail")
+	}
+	if game.Name() != "Previous" {
+		t.Errorf("game.Name() after a failed query: %s != Previous", game.Name())
+	}
+}
+
+func TestGameServerReconfigureWhileQuerying(t *testing.T) {
+	startResponder(t, "127.0.0.1:28976", GameServerStatus{Name: "Live", Game: "Tribes", Version: "1.11"})
+
+	game := NewGameServer("127.0.0.1:28976")
+	stop := make(chan struct{})
+	reconfigured := make(chan struct{})
+	go func() {
+		defer close(reconfigured)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			game.SetTimeout(time.Duration(i%3+1) * time.Second)
+			game.SetLimits(Limits{MaxStringLength: 200 + i%50})
+			game.SetRetries(i%2, time.Millisecond)
+			game.SetArenaParsing(i%2 == 0)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		err := game.Query(0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if game.Name() != "Live" {
+			t.Errorf("game.Name(): %s != Live", game.Name())
+		}
+	}
+	close(stop)
+	<-reconfigured
+}
+
+func TestGameServerLastKnownGood(t *testing.T) {
+	responder := NewQueryResponder("127.0.0.1:28975")
+	responder.SetStatus(GameServerStatus{
+		Name:    "Flaky",
+		Game:    "Tribes",
+		Version: "1.11",
+		Players: []Player{{Name: "Alpha"}},
+	})
+	stop := serveResponder(t, responder, "127.0.0.1:28975")
+
+	game := NewGameServer("127.0.0.1:28975")
+	if game.Online() || !game.LastSuccess().IsZero() {
+		t.Errorf("game.Online(): %v, game.LastSuccess(): %s before the first query", game.Online(), game.LastSuccess())
+	}
+	err := game.Query(time.Second, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastSuccess := game.LastSuccess()
+	if !game.Online() || game.LastError() != nil || lastSuccess.IsZero() {
+		t.Errorf("game.Online(): %v, game.LastError(): %v, game.LastSuccess(): %s", game.Online(), game.LastError(), lastSuccess)
+	}
+
+	stop()
+	err = game.Query(50*time.Millisecond, "")
+	if err == nil {
+		t.Fatal("expected a query to a closed responder to fail")
+	}
+	if game.Online() || game.LastError() != err || !game.LastSuccess().Equal(lastSuccess) {
+		t.Errorf("game.Online(): %v, game.LastError(): %v, game.LastSuccess(): %s", game.Online(), game.LastError(), game.LastSuccess())
+	}
+	if game.Name() != "Flaky" || len(game.Players()) != 1 {
+		t.Errorf("game.Name(): %s, game.Players(): %v", game.Name(), game.Players())
+	}
+}
+
+func TestQueryIPv6(t *testing.T) {
+	probe, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Skipf("No IPv6 loopback: %v", err)
+	}
+	_ = probe.Close()
+
+	status := GameServerStatus{Name: "IPv6", Game: "Tribes", Version: "1.41"}
+	closer := replyOnce(t, "[::1]:28958", func(key uint16) []byte {
+		var buffer bytes.Buffer
+		if err := status.WriteReply(&buffer, key); err != nil {
+			t.Error(err)
+		}
+		return buffer.Bytes()
+	})
+	defer closer()
+
+	g := NewGameServer("[::1]:28958")
+	err = g.QueryWith(context.Background(), WithNetwork("udp4"), WithQueryTimeout(100*time.Millisecond))
+	if err == nil {
+		t.Errorf("QueryWith(WithNetwork(udp4)): IPv6 address queried")
+	}
+	err = g.QueryWith(context.Background(), WithQueryTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("QueryWith(): %v", err)
+	}
+	if g.Name() != "IPv6" {
+		t.Errorf("Name(): %v != IPv6", g.Name())
+	}
+}
+
+// pipeDialer returns a DialFunc connecting to an in-memory server that
+// answers each request with the packets reply returns.
+func pipeDialer(reply func(request []byte) [][]byte) DialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			readBuffer := make([]byte, 64)
+			n, err := server.Read(readBuffer)
+			if err != nil {
+				return
+			}
+			for _, packet := range reply(readBuffer[0:n]) {
+				if _, err = server.Write(packet); err != nil {
+					return
+				}
+			}
+		}()
+		return client, nil
+	}
+}
+
+func TestQueryWithDialer(t *testing.T) {
+	status := GameServerStatus{Name: "Piped", Players: []Player{{Name: "Alpha", Score: "5"}}}
+	dial := pipeDialer(func(request []byte) [][]byte {
+		var buffer bytes.Buffer
+		if err := status.WriteReply(&buffer, binary.BigEndian.Uint16(request[1:3])); err != nil {
+			t.Error(err)
+		}
+		return [][]byte{buffer.Bytes()}
+	})
+
+	g := NewGameServer("192.0.2.1:28001")
+	err := g.QueryWith(context.Background(), WithDialer(dial), WithQueryTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("QueryWith(): %v", err)
+	}
+	if g.Name() != "Piped" || g.NumPlayers() != 1 {
+		t.Errorf("Status(): %+v", g.Status())
+	}
+
+	list := MasterServerStatus{Name: "Piped"}
+	for i := 0; i < 200; i++ {
+		list.Servers = append(list.Servers, fmt.Sprintf("192.0.2.%d:28001", i))
+	}
+	dial = pipeDialer(func(request []byte) [][]byte {
+		packets, err := list.WriteReply(binary.BigEndian.Uint16(request[4:6]))
+		if err != nil {
+			t.Error(err)
+		}
+		return packets
+	})
+
+	m := NewMasterServer("192.0.2.2:28000")
+	err = m.QueryWith(context.Background(), WithDialer(dial), WithQueryTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("QueryWith(): %v", err)
+	}
+	if servers := m.Servers(); len(servers) != 200 {
+		t.Errorf("len(Servers()): %v != 200", len(servers))
+	}
+}
+
+func TestGameServerEchoWith(t *testing.T) {
+	var echoed int
+	dial := pipeDialer(func(request []byte) [][]byte {
+		echoed++
+		return [][]byte{append([]byte(nil), request...)}
+	})
+	resolver := &countingResolver{hosts: map[string]net.IP{"tribes.example": net.IPv4(192, 0, 2, 1)}}
+	g := NewGameServer("tribes.example:28001")
+	ping, err := g.EchoWith(context.Background(), WithDialer(dial), WithResolver(resolver), WithQueryTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("EchoWith(): %v", err)
+	}
+	if ping <= 0 || echoed != 1 || resolver.lookups != 1 {
+		t.Errorf("EchoWith(): ping %v, echoed %d != 1, lookups %d != 1", ping, echoed, resolver.lookups)
+	}
+
+	dial = pipeDialer(func(request []byte) [][]byte {
+		return [][]byte{[]byte("\\echo\\other")}
+	})
+	if _, err = g.EchoWith(context.Background(), WithDialer(dial), WithResolver(resolver), WithQueryTimeout(time.Second)); err == nil {
+		t.Error("EchoWith(): expected an error for a mismatched reply")
+	}
+	if _, err = g.EchoWith(context.Background(), WithSimulation(NewSimulation())); err == nil {
+		t.Error("EchoWith(): expected an error with a simulation")
+	}
+}
@@ -0,0 +1,79 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"sort"
+	"time"
+)
+
+// ServerRecords are the records a server has set, the data behind community
+// "records" pages.  It encodes as JSON with encoding/json as is.
+type ServerRecords struct {
+	Address string
+	// PeakPlayers is the most players seen at once, first seen at PeakTime.
+	PeakPlayers uint8
+	PeakTime    time.Time
+	// LongestMission is the mission played for longest in one go, for
+	// LongestDuration.  Only plays followed by another mission count, as
+	// the end of the last one is not known.
+	LongestMission  string
+	LongestDuration time.Duration
+	// MostPlayedMission is the mission seen being played most often,
+	// MostPlays times.  Ties go to the mission first in alphabetical order.
+	MostPlayedMission string
+	MostPlays         int
+}
+
+// BuildRecords works out the records of every server in statuses, grouped
+// by address and read in QueryTime order the way InferRotation reads them.
+// The records are sorted by address.
+func BuildRecords(statuses []GameServerStatus) (records []ServerRecords) {
+	byAddress := make(map[string][]GameServerStatus)
+	for _, status := range statuses {
+		byAddress[status.Address] = append(byAddress[status.Address], status)
+	}
+
+	for address, history := range byAddress {
+		sort.SliceStable(history, func(i, j int) bool { return history[i].QueryTime.Before(history[j].QueryTime) })
+		r := ServerRecords{Address: address}
+		for _, status := range history {
+			if status.NumPlayers > r.PeakPlayers {
+				r.PeakPlayers, r.PeakTime = status.NumPlayers, status.QueryTime
+			}
+		}
+
+		runs := missionRuns(history)
+		plays := make(map[string]int)
+		for i, run := range runs {
+			plays[run.mission]++
+			if i+1 < len(runs) {
+				if d := runs[i+1].start.Sub(run.start); d > r.LongestDuration {
+					r.LongestMission, r.LongestDuration = run.mission, d
+				}
+			}
+		}
+		for mission, count := range plays {
+			if count > r.MostPlays || count == r.MostPlays && mission < r.MostPlayedMission {
+				r.MostPlayedMission, r.MostPlays = mission, count
+			}
+		}
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Address < records[j].Address })
+	return
+}
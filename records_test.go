@@ -0,0 +1,71 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildRecords(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	poll := func(address string, minutes int, mission string, players uint8) GameServerStatus {
+		return GameServerStatus{
+			Address:    address,
+			QueryTime:  start.Add(time.Duration(minutes) * time.Minute),
+			Mission:    mission,
+			NumPlayers: players,
+		}
+	}
+	// Out of order on purpose: records follow QueryTime.
+	statuses := []GameServerStatus{
+		poll("b:28001", 0, "Sanctuary", 3),
+		poll("a:28001", 30, "Broadside", 12),
+		poll("a:28001", 0, "Raindance", 4),
+		poll("a:28001", 10, "Raindance", 12),
+		poll("a:28001", 40, "Raindance", 8),
+		poll("a:28001", 50, "Broadside", 2),
+	}
+
+	records := BuildRecords(statuses)
+	if len(records) != 2 {
+		t.Fatalf("BuildRecords(): %v", records)
+	}
+	expected := ServerRecords{
+		Address:         "a:28001",
+		PeakPlayers:     12,
+		PeakTime:        start.Add(10 * time.Minute),
+		LongestMission:  "Raindance",
+		LongestDuration: 30 * time.Minute,
+		// Broadside ties with Raindance and comes first alphabetically.
+		MostPlayedMission: "Broadside",
+		MostPlays:         2,
+	}
+	if records[0] != expected {
+		t.Errorf("records[0]: %+v != %+v", records[0], expected)
+	}
+	expected = ServerRecords{
+		Address:           "b:28001",
+		PeakPlayers:       3,
+		PeakTime:          start,
+		MostPlayedMission: "Sanctuary",
+		MostPlays:         1,
+	}
+	if records[1] != expected {
+		t.Errorf("records[1]: %+v != %+v", records[1], expected)
+	}
+}
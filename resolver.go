@@ -0,0 +1,106 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// Resolver looks up the IP addresses of a host on network, "ip", "ip4" or
+// "ip6".  *net.Resolver is one; set it on queries with WithResolver.
+type Resolver interface {
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+}
+
+// CachingResolver is a Resolver remembering successful lookups for a fixed
+// time, so that repeatedly querying servers by host name does not repeat
+// the DNS lookup every time.  Failures are not cached.  It is safe for
+// concurrent use.
+type CachingResolver struct {
+	mutex    sync.Mutex
+	resolver Resolver
+	ttl      time.Duration
+	entries  map[cachedLookup]cachedIPs
+}
+
+type cachedLookup struct {
+	network string
+	host    string
+}
+
+type cachedIPs struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+// LookupIP returns the cached addresses of host, looking them up with the
+// underlying resolver once they are older than the TTL.  IP addresses are
+// returned as they are.
+func (r *CachingResolver) LookupIP(ctx context.Context, network, host string) (ips []net.IP, err error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return r.resolver.LookupIP(ctx, network, host)
+	}
+
+	key := cachedLookup{network: network, host: host}
+	now := time.Now()
+	r.mutex.Lock()
+	entry, ok := r.entries[key]
+	if ok && now.Before(entry.expires) {
+		r.mutex.Unlock()
+		return append([]net.IP(nil), entry.ips...), nil
+	}
+	r.mutex.Unlock()
+
+	ips, err = r.resolver.LookupIP(ctx, network, host)
+	if err != nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.entries[key] = cachedIPs{ips: append([]net.IP(nil), ips...), expires: now.Add(r.ttl)}
+	// Expired entries are dropped as new ones are added, so hosts no longer
+	// queried do not accumulate.
+	for other, entry := range r.entries {
+		if !now.Before(entry.expires) {
+			delete(r.entries, other)
+		}
+	}
+	return
+}
+
+// Flush forgets every cached lookup.
+func (r *CachingResolver) Flush() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.entries = make(map[cachedLookup]cachedIPs)
+}
+
+// NewCachingResolver returns a CachingResolver caching the lookups of
+// resolver for ttl.  A nil resolver uses net.DefaultResolver.
+func NewCachingResolver(resolver Resolver, ttl time.Duration) *CachingResolver {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &CachingResolver{
+		resolver: resolver,
+		ttl:      ttl,
+		entries:  make(map[cachedLookup]cachedIPs),
+	}
+}
@@ -0,0 +1,177 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// countingResolver resolves every host in hosts and counts its lookups.
+type countingResolver struct {
+	hosts   map[string]net.IP
+	lookups int
+}
+
+func (r *countingResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	r.lookups++
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	ip, ok := r.hosts[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return []net.IP{ip}, nil
+}
+
+func TestCachingResolver(t *testing.T) {
+	counting := &countingResolver{hosts: map[string]net.IP{"tribes.example": net.IPv4(127, 0, 0, 1)}}
+	resolver := NewCachingResolver(counting, 50*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		ips, err := resolver.LookupIP(context.Background(), "ip", "tribes.example")
+		if err != nil || len(ips) != 1 || !ips[0].Equal(net.IPv4(127, 0, 0, 1)) {
+			t.Errorf("LookupIP(): %v, %v", ips, err)
+		}
+	}
+	if counting.lookups != 1 {
+		t.Errorf("lookups: %v != 1", counting.lookups)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	_, _ = resolver.LookupIP(context.Background(), "ip", "tribes.example")
+	if counting.lookups != 2 {
+		t.Errorf("lookups after the TTL: %v != 2", counting.lookups)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := resolver.LookupIP(context.Background(), "ip", "missing.example"); err == nil {
+			t.Errorf("LookupIP(missing.example): No error")
+		}
+	}
+	if counting.lookups != 4 {
+		t.Errorf("lookups after failures: %v != 4", counting.lookups)
+	}
+
+	resolver.Flush()
+	_, _ = resolver.LookupIP(context.Background(), "ip", "tribes.example")
+	if counting.lookups != 5 {
+		t.Errorf("lookups after Flush(): %v != 5", counting.lookups)
+	}
+}
+
+func TestQueryWithResolver(t *testing.T) {
+	status := GameServerStatus{Name: "Resolved", Game: "Tribes", Version: "1.41"}
+	closer := replyOnce(t, "127.0.0.1:28953", func(key uint16) []byte {
+		var buffer bytes.Buffer
+		if err := status.WriteReply(&buffer, key); err != nil {
+			t.Error(err)
+		}
+		return buffer.Bytes()
+	})
+	defer closer()
+
+	resolver := &countingResolver{hosts: map[string]net.IP{"tribes.example": net.IPv4(127, 0, 0, 1)}}
+	game := NewGameServer("tribes.example:28953")
+	err := game.QueryWith(context.Background(), WithResolver(resolver), WithQueryTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("QueryWith(): %v", err)
+	}
+	if game.Name() != "Resolved" || resolver.lookups != 1 {
+		t.Errorf("Name(), lookups: %v, %v", game.Name(), resolver.lookups)
+	}
+}
+
+// slowResolver is a countingResolver taking delay to answer.
+type slowResolver struct {
+	countingResolver
+	delay time.Duration
+}
+
+func (r *slowResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	time.Sleep(r.delay)
+	return r.countingResolver.LookupIP(ctx, network, host)
+}
+
+func TestGameServerResolve(t *testing.T) {
+	status := GameServerStatus{Name: "Resolved"}
+	dial := pipeDialer(func(request []byte) [][]byte {
+		var buffer bytes.Buffer
+		if err := status.WriteReply(&buffer, binary.BigEndian.Uint16(request[1:3])); err != nil {
+			t.Error(err)
+		}
+		return [][]byte{buffer.Bytes()}
+	})
+	resolver := &slowResolver{
+		countingResolver: countingResolver{hosts: map[string]net.IP{"tribes.example": net.IPv4(192, 0, 2, 1)}},
+		delay:            50 * time.Millisecond,
+	}
+
+	game := NewGameServer("tribes.example:28001")
+	err := game.QueryWith(context.Background(), WithDialer(dial), WithResolver(resolver), WithQueryTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("QueryWith(): %v", err)
+	}
+	if resolver.lookups != 1 || game.Ping() >= resolver.delay {
+		t.Errorf("lookups, Ping(): %v != 1, %v includes the lookup", resolver.lookups, game.Ping())
+	}
+
+	err = game.Resolve(context.Background(), WithResolver(resolver))
+	if err != nil {
+		t.Fatalf("Resolve(): %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		err = game.QueryWith(context.Background(), WithDialer(dial), WithResolver(resolver), WithQueryTimeout(time.Second))
+		if err != nil {
+			t.Fatalf("QueryWith(): %v", err)
+		}
+	}
+	if resolver.lookups != 2 || game.Name() != "Resolved" {
+		t.Errorf("lookups after Resolve(): %v != 2", resolver.lookups)
+	}
+
+	// Addresses kept for "udp" are not used for "udp6".
+	err = game.QueryWith(context.Background(), WithDialer(dial), WithResolver(resolver), WithNetwork("udp6"))
+	if err == nil || resolver.lookups != 3 {
+		t.Errorf("QueryWith(udp6): %v, lookups: %v != 3", err, resolver.lookups)
+	}
+
+	// A failed Resolve keeps the addresses resolved before.
+	delete(resolver.hosts, "tribes.example")
+	if err = game.Resolve(context.Background(), WithResolver(resolver)); err == nil {
+		t.Error("Resolve(): expected an error for an unknown host")
+	}
+	err = game.QueryWith(context.Background(), WithDialer(dial), WithQueryTimeout(time.Second))
+	if err != nil {
+		t.Errorf("QueryWith() after a failed Resolve(): %v", err)
+	}
+
+	master := NewMasterServer("missing.example:28000")
+	if err = master.Resolve(context.Background(), WithResolver(resolver)); err == nil {
+		t.Error("master.Resolve(): expected an error for an unknown host")
+	}
+	master = NewMasterServer("192.0.2.1:28000")
+	if err = master.Resolve(context.Background(), WithResolver(resolver)); err != nil {
+		t.Errorf("master.Resolve(): %v", err)
+	}
+}
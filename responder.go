@@ -0,0 +1,396 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type AccessOutcome string
+
+const (
+	// AccessAnswered means a reply was sent.
+	AccessAnswered AccessOutcome = "answered"
+	// AccessIgnored means the request was not recognised and not answered.
+	AccessIgnored AccessOutcome = "ignored"
+	// AccessFailed means building or sending the reply failed.
+	AccessFailed AccessOutcome = "failed"
+)
+
+// AccessRecord describes one request handled by a server side component.
+type AccessRecord struct {
+	Time   time.Time
+	Source net.Addr
+	// Opcode is the kind of request: "query" for the binary query, "echo",
+	// the text of a GameSpy text query such as \status\, or "unknown".
+	Opcode   string
+	BytesIn  int
+	BytesOut int
+	Outcome  AccessOutcome
+	Err      error
+}
+
+// QueryResponder answers GameSpy queries on behalf of a game server using
+// the status set with SetStatus.  Both the binary query used by Tribes and
+// the GameSpy text protocol (\basic\, \info\, \rules\, \players\ and
+// \status\) are answered on the same port.
+type QueryResponder struct {
+	mutex     sync.RWMutex
+	addresses []string
+	status    GameServerStatus
+	conns     []*net.UDPConn
+	queryID   int
+	accessLog func(record AccessRecord)
+	failed    uint64
+	closed    bool
+
+	backend        *GameServer
+	backendTTL     time.Duration
+	backendTimeout time.Duration
+	backendFetched time.Time
+	backendOK      bool
+	refreshing     bool
+}
+
+func (r *QueryResponder) Status() (status GameServerStatus) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return copyStatus(r.status)
+}
+
+func (r *QueryResponder) SetStatus(status GameServerStatus) {
+	status = copyStatus(status)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.status = status
+}
+
+// ListenAndServe listens on every address the responder was created with
+// and answers queries on all of them until Close is called or one of them
+// fails.  An address without a host, such as ":28001", listens on both
+// IPv4 and IPv6, so IPv6-only clients are served too.
+func (r *QueryResponder) ListenAndServe() (err error) {
+	if len(r.addresses) == 0 {
+		return errors.New("t1net.QueryResponder.ListenAndServe: No address to listen on")
+	}
+
+	var conns []*net.UDPConn
+	for _, address := range r.addresses {
+		var listening []*net.UDPConn
+		listening, err = listenMirrored(address)
+		conns = append(conns, listening...)
+		if err != nil {
+			for _, c := range conns {
+				_ = c.Close()
+			}
+			return
+		}
+	}
+
+	errs := make(chan error, len(conns))
+	for _, c := range conns {
+		go func(c *net.UDPConn) {
+			errs <- r.Serve(c)
+		}(c)
+	}
+	// The first listener to stop stops the others, so that none is left
+	// answering alone.
+	err = <-errs
+	for _, c := range conns {
+		_ = c.Close()
+	}
+	for i := 1; i < len(conns); i++ {
+		if serveErr := <-errs; err == nil {
+			err = serveErr
+		}
+	}
+	return
+}
+
+// listenMirrored listens on address, or on both its IPv4 and IPv6 wildcard
+// addresses if it has no host.
+func listenMirrored(address string) (conns []*net.UDPConn, err error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return
+	}
+	if len(host) != 0 {
+		var localAddr *net.UDPAddr
+		localAddr, err = net.ResolveUDPAddr("udp", address)
+		if err != nil {
+			return
+		}
+		c, err := net.ListenUDP("udp", localAddr)
+		if err != nil {
+			return nil, err
+		}
+		return []*net.UDPConn{c}, nil
+	}
+
+	for _, network := range []string{"udp4", "udp6"} {
+		var localAddr *net.UDPAddr
+		localAddr, err = net.ResolveUDPAddr(network, net.JoinHostPort("", port))
+		if err != nil {
+			return
+		}
+		var c *net.UDPConn
+		c, err = net.ListenUDP(network, localAddr)
+		if err != nil {
+			return
+		}
+		conns = append(conns, c)
+		// Answer on the same port over both, even when it was chosen by
+		// the system.
+		port = strconv.Itoa(c.LocalAddr().(*net.UDPAddr).Port)
+	}
+	return
+}
+
+// Serve answers queries read from c until c is closed or Close is called.
+// It can be called for several sockets at once, all answered alike.  A
+// reply that cannot be built, such as one with a status string over 255
+// bytes, is not sent; it is counted by Failed and logged as AccessFailed,
+// and serving goes on.
+func (r *QueryResponder) Serve(c *net.UDPConn) (err error) {
+	// Close may run before c is registered; it must still stop Serve.
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		_ = c.Close()
+		return
+	}
+	r.conns = append(r.conns, c)
+	r.mutex.Unlock()
+
+	defer func() {
+		_ = c.Close()
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		for i, conn := range r.conns {
+			if conn == c {
+				r.conns = append(r.conns[:i], r.conns[i+1:]...)
+				break
+			}
+		}
+	}()
+
+	readBuffer := make([]byte, 64)
+	var (
+		n       int
+		addr    *net.UDPAddr
+		replies [][]byte
+		written int
+	)
+	for {
+		n, addr, err = c.ReadFromUDP(readBuffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				err = nil
+			}
+			return
+		}
+
+		record := AccessRecord{Time: time.Now(), Source: addr, BytesIn: n, Outcome: AccessIgnored}
+		replies, record.Opcode, err = r.reply(c, readBuffer[0:n])
+		if err != nil {
+			record.Outcome, record.Err = AccessFailed, err
+			err = nil
+			r.mutex.Lock()
+			r.failed++
+			r.mutex.Unlock()
+			r.logAccess(record)
+			continue
+		}
+
+		for _, packet := range replies {
+			record.Outcome = AccessAnswered
+			// A failed reply to one client should not stop the responder.
+			written, err = c.WriteToUDP(packet, addr)
+			record.BytesOut += written
+			if err != nil {
+				record.Outcome, record.Err = AccessFailed, err
+				err = nil
+				break
+			}
+		}
+		r.logAccess(record)
+	}
+}
+
+// SetBackend turns the responder into a caching proxy for backend: replies
+// are built from backend's status, which is queried again once it is older
+// than ttl.  Only the first query blocks; later ones run in the background
+// while the previous status is served.  Requests are ignored until backend
+// has answered once.  A nil backend stops proxying; the last status fetched
+// keeps being served until SetStatus is called.
+func (r *QueryResponder) SetBackend(backend *GameServer, ttl, timeout time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.backend = backend
+	r.backendTTL = ttl
+	r.backendTimeout = timeout
+	r.backendFetched = time.Time{}
+	r.backendOK = false
+}
+
+// refreshBackend queries the backend if its status has expired.  It returns
+// false if there is a backend that has never answered, leaving nothing to
+// serve.
+func (r *QueryResponder) refreshBackend() (ok bool) {
+	r.mutex.Lock()
+	backend := r.backend
+	if backend == nil {
+		r.mutex.Unlock()
+		return true
+	}
+	if r.refreshing || !r.backendFetched.IsZero() && time.Since(r.backendFetched) < r.backendTTL {
+		ok = r.backendOK
+		r.mutex.Unlock()
+		return
+	}
+	r.refreshing = true
+	first := r.backendFetched.IsZero()
+	timeout := r.backendTimeout
+	r.mutex.Unlock()
+
+	refresh := func() {
+		err := backend.Query(timeout, "")
+
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.refreshing = false
+		if r.backend != backend {
+			return
+		}
+		r.backendFetched = time.Now()
+		if err == nil {
+			r.status = backend.Status()
+			r.backendOK = true
+		}
+	}
+
+	if first {
+		refresh()
+	} else {
+		go refresh()
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.backendOK
+}
+
+// reply returns the packets answering the request in packet, and the kind
+// of request it was.
+func (r *QueryResponder) reply(c *net.UDPConn, packet []byte) (replies [][]byte, opcode string, err error) {
+	switch {
+	case bytes.HasPrefix(packet, []byte("\\echo\\")):
+		echo := make([]byte, len(packet))
+		copy(echo, packet)
+		return [][]byte{echo}, "echo", nil
+
+	case len(packet) > 0 && packet[0] == '\\':
+		opcode = string(packet)
+		if !r.refreshBackend() {
+			return
+		}
+		port := 0
+		if localAddr, ok := c.LocalAddr().(*net.UDPAddr); ok {
+			port = localAddr.Port
+		}
+
+		var pairs []string
+		r.mutex.Lock()
+		for _, name := range strings.Split(opcode, "\\") {
+			pairs = append(pairs, r.status.gameSpyPairs(name, port)...)
+		}
+		r.queryID++
+		queryID := r.queryID
+		r.mutex.Unlock()
+
+		if len(pairs) != 0 {
+			replies = gameSpyPackets(pairs, queryID)
+		}
+		return
+
+	// 0x62 = GameSpy query request, next two bytes are key
+	case len(packet) == 3 && packet[0] == 0x62:
+		if !r.refreshBackend() {
+			return nil, "query", nil
+		}
+		var buffer bytes.Buffer
+		r.mutex.RLock()
+		err = r.status.WriteReply(&buffer, binary.BigEndian.Uint16(packet[1:3]))
+		r.mutex.RUnlock()
+		return [][]byte{buffer.Bytes()}, "query", err
+	}
+
+	return nil, "unknown", nil
+}
+
+// SetAccessLog makes the responder call log for every request it reads.  log
+// is called from the serving goroutine, so it should hand records off
+// rather than block.  A nil log disables access logging.
+func (r *QueryResponder) SetAccessLog(log func(record AccessRecord)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.accessLog = log
+}
+
+func (r *QueryResponder) logAccess(record AccessRecord) {
+	r.mutex.RLock()
+	log := r.accessLog
+	r.mutex.RUnlock()
+	if log != nil {
+		log(record)
+	}
+}
+
+// Failed returns how many requests were not answered because their reply
+// could not be built.
+func (r *QueryResponder) Failed() (failed uint64) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.failed
+}
+
+// Close stops every Serve and ListenAndServe call, including those that
+// have not started reading yet.  A closed responder cannot serve again.
+func (r *QueryResponder) Close() (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.closed = true
+	for _, c := range r.conns {
+		if closeErr := c.Close(); closeErr != nil && !errors.Is(closeErr, net.ErrClosed) && err == nil {
+			err = closeErr
+		}
+	}
+	return
+}
+
+// NewQueryResponder returns a QueryResponder that listens on addresses, or
+// on both IPv4 and IPv6 for an address without a host.
+func NewQueryResponder(addresses ...string) *QueryResponder {
+	return &QueryResponder{addresses: addresses}
+}
@@ -0,0 +1,369 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueryResponder(t *testing.T) {
+	responder := NewQueryResponder("127.0.0.1:28998")
+	responder.SetStatus(GameServerStatus{
+		Name:              "Fake Server",
+		Game:              "Tribes",
+		Version:           "1.11",
+		Dedicated:         true,
+		MaxPlayers:        32,
+		CPUSpeed:          3500,
+		Mod:               "base",
+		ServerType:        "CTF",
+		Mission:           "Raindance",
+		Info:              "Testing",
+		TeamScoreHeader:   "Team\tScore",
+		PlayerScoreHeader: "Name\tScore",
+		Teams: []Team{
+			{Name: "Blood Eagle", Score: "2"},
+			{Name: "Diamond Sword", Score: "1"},
+		},
+		Players: []Player{
+			{Name: "Alpha", Team: 0, Score: "10", Ping: 50, PL: 0},
+			{Name: "Beta", Team: 1, Score: "7", Ping: 120, PL: 3},
+			{Name: "Gamma", Team: 255, Score: "0", Ping: 80, PL: 0},
+		},
+	})
+
+	records := make(chan AccessRecord, 16)
+	responder.SetAccessLog(func(record AccessRecord) {
+		records <- record
+	})
+
+	s, err := net.ResolveUDPAddr("udp4", "127.0.0.1:28998")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := net.ListenUDP("udp4", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- responder.Serve(c)
+	}()
+
+	game := NewGameServer("127.0.0.1:28998")
+	err = game.Query(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if game.Name() != "Fake Server" {
+		t.Errorf("game.Name(): %s != Fake Server", game.Name())
+	}
+	if game.Mission() != "Raindance" {
+		t.Errorf("game.Mission(): %s != Raindance", game.Mission())
+	}
+	if game.CPUSpeed() != 3500 {
+		t.Errorf("game.CPUSpeed(): %d != 3500", game.CPUSpeed())
+	}
+	if game.NumTeams() != 2 {
+		t.Errorf("game.NumTeams(): %d != 2", game.NumTeams())
+	}
+	if game.NumPlayers() != 3 {
+		t.Errorf("game.NumPlayers(): %d != 3", game.NumPlayers())
+	}
+
+	expected := responder.Status()
+	for _, arena := range []bool{false, true} {
+		if arena {
+			game.SetArenaParsing(true)
+			err = game.Query(0, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		players := game.Players()
+		if len(players) != len(expected.Players) {
+			t.Fatalf("game.Players(): Length mismatch: %d != %d", len(players), len(expected.Players))
+		}
+		for i, player := range players {
+			if player != expected.Players[i] {
+				t.Errorf("game.Players(): Player mismatch (arena %t): %v != %v", arena, player, expected.Players[i])
+			}
+		}
+	}
+
+	ping, err := game.Echo(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ping <= 0 {
+		t.Errorf("game.Echo(): ping %s <= 0", ping)
+	}
+
+	client, err := net.DialUDP("udp4", nil, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	_, err = client.Write([]byte("\\status\\"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.SetDeadline(time.Now().Add(1 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBuffer := make([]byte, 2048)
+	n, err := client.Read(readBuffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(readBuffer[0:n], []byte("\\hostname\\Fake Server\\hostport\\28998")) {
+		t.Errorf("\\status\\ reply: %q", readBuffer[0:n])
+	}
+
+	err = responder.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	close(records)
+	var opcodes []string
+	for record := range records {
+		if record.Outcome != AccessAnswered || record.BytesIn == 0 || record.BytesOut == 0 {
+			t.Errorf("unexpected access record: %v", record)
+		}
+		opcodes = append(opcodes, record.Opcode)
+	}
+	expectedOpcodes := []string{"query", "query", "echo", "\\status\\"}
+	if len(opcodes) != len(expectedOpcodes) {
+		t.Fatalf("access log opcodes: %v != %v", opcodes, expectedOpcodes)
+	}
+	for i := range opcodes {
+		if opcodes[i] != expectedOpcodes[i] {
+			t.Fatalf("access log opcodes: %v != %v", opcodes, expectedOpcodes)
+		}
+	}
+}
+
+// serveResponder serves responder on addresses, binding both IPv4 and IPv6
+// for an address without a host as ListenAndServe does.  The sockets are
+// bound before it returns, so the responder can be queried straight away.
+// The returned function closes the responder and waits for it to stop; it
+// also runs when the test ends.
+func serveResponder(t *testing.T, responder *QueryResponder, addresses ...string) (stop func()) {
+	t.Helper()
+	var conns []*net.UDPConn
+	for _, address := range addresses {
+		listening, err := listenMirrored(address)
+		conns = append(conns, listening...)
+		if err != nil {
+			for _, c := range conns {
+				_ = c.Close()
+			}
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan error, len(conns))
+	for _, c := range conns {
+		go func(c *net.UDPConn) {
+			done <- responder.Serve(c)
+		}(c)
+	}
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			if err := responder.Close(); err != nil {
+				t.Error(err)
+			}
+			for range conns {
+				if err := <-done; err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+	t.Cleanup(stop)
+	return
+}
+
+// waitServing waits until responder is serving conns sockets, as
+// ListenAndServe binds them in the background.
+func waitServing(t *testing.T, responder *QueryResponder, conns int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		responder.mutex.RLock()
+		serving := len(responder.conns)
+		responder.mutex.RUnlock()
+		if serving == conns {
+			return
+		}
+	}
+	t.Fatalf("responder is not serving %d sockets", conns)
+}
+
+// startResponder serves status on address until the test ends, as
+// serveResponder does.
+func startResponder(t *testing.T, address string, status GameServerStatus) (responder *QueryResponder) {
+	t.Helper()
+	responder = NewQueryResponder(address)
+	responder.SetStatus(status)
+	serveResponder(t, responder, address)
+	return
+}
+
+func TestQueryResponderBackend(t *testing.T) {
+	backend := NewQueryResponder("127.0.0.1:28991")
+	backend.SetStatus(GameServerStatus{Name: "Backend", Players: []Player{{Name: "Alpha"}}})
+	defer serveResponder(t, backend, "127.0.0.1:28991")()
+
+	proxy := NewQueryResponder("127.0.0.1:28990")
+	proxy.SetBackend(NewGameServer("127.0.0.1:28991"), time.Minute, time.Second)
+	defer serveResponder(t, proxy, "127.0.0.1:28990")()
+
+	game := NewGameServer("127.0.0.1:28990")
+	err := game.Query(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Name() != "Backend" || len(game.Players()) != 1 {
+		t.Errorf("proxied status: %s, %v", game.Name(), game.Players())
+	}
+
+	// The cached status is served until the TTL expires.
+	backend.SetStatus(GameServerStatus{Name: "Changed"})
+	err = game.Query(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Name() != "Backend" {
+		t.Errorf("game.Name(): %s != Backend", game.Name())
+	}
+}
+
+func TestQueryResponderDualStack(t *testing.T) {
+	probe, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Skipf("No IPv6 loopback: %v", err)
+	}
+	_ = probe.Close()
+
+	responder := NewQueryResponder(":28955", "127.0.0.1:28954")
+	responder.SetStatus(GameServerStatus{Name: "Dual Stack", Game: "Tribes", Version: "1.41"})
+	served := make(chan error, 1)
+	go func() {
+		served <- responder.ListenAndServe()
+	}()
+	waitServing(t, responder, 3)
+
+	for _, address := range []string{"127.0.0.1:28955", "[::1]:28955", "127.0.0.1:28954"} {
+		game := NewGameServer(address)
+		if err := game.Query(time.Second, ""); err != nil {
+			t.Errorf("Query(%v): %v", address, err)
+			continue
+		}
+		if game.Name() != "Dual Stack" {
+			t.Errorf("Name(%v): %v != Dual Stack", address, game.Name())
+		}
+	}
+
+	if err = responder.Close(); err != nil {
+		t.Errorf("Close(): %v", err)
+	}
+	select {
+	case err = <-served:
+		if err != nil {
+			t.Errorf("ListenAndServe(): %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("ListenAndServe() did not return after Close()")
+	}
+}
+
+func TestQueryResponderNoAddress(t *testing.T) {
+	served := make(chan error, 1)
+	go func() {
+		served <- NewQueryResponder().ListenAndServe()
+	}()
+	select {
+	case err := <-served:
+		if err == nil {
+			t.Errorf("ListenAndServe(): expected an error without an address")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("ListenAndServe() without an address did not return")
+	}
+}
+
+func TestQueryResponderReplyFailure(t *testing.T) {
+	responder := NewQueryResponder("127.0.0.1:28950")
+	responder.SetStatus(GameServerStatus{Name: strings.Repeat("x", 300)})
+	stop := serveResponder(t, responder, "127.0.0.1:28950")
+	defer stop()
+
+	game := NewGameServer("127.0.0.1:28950")
+	if err := game.Query(100*time.Millisecond, ""); err == nil {
+		t.Error("Query(): expected no reply for a status that cannot be written")
+	}
+	if responder.Failed() != 1 {
+		t.Errorf("responder.Failed(): %d != 1", responder.Failed())
+	}
+
+	// The responder is still serving.
+	responder.SetStatus(GameServerStatus{Name: "Recovered"})
+	if err := game.Query(time.Second, ""); err != nil || game.Name() != "Recovered" {
+		t.Errorf("Query(): %v, game.Name(): %s", err, game.Name())
+	}
+}
+
+func TestQueryResponderCloseBeforeServe(t *testing.T) {
+	c, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	responder := NewQueryResponder()
+	if err = responder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- responder.Serve(c)
+	}()
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Errorf("Serve(): %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Serve() after Close() did not return")
+		_ = c.Close()
+	}
+}
@@ -0,0 +1,109 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import "strings"
+
+// ObserversTeamName names the synthetic team used for players whose team
+// index matches no listed team, usually observers (team 255).
+const ObserversTeamName = "Observers"
+
+// UnassignedPolicy decides what happens to players whose team index matches
+// no listed team.
+type UnassignedPolicy int
+
+const (
+	// UnassignedObservers places them in a synthetic ObserversTeamName team.
+	UnassignedObservers UnassignedPolicy = iota
+	// UnassignedDrop leaves them out of team groupings.
+	UnassignedDrop
+)
+
+// PlayerTeam returns the team player belongs to.  Players with a team index
+// that matches no listed team are handled according to policy; ok is false
+// if the policy drops them.
+func (s *GameServerStatus) PlayerTeam(player Player, policy UnassignedPolicy) (team Team, ok bool) {
+	if int(player.Team) < len(s.Teams) {
+		return s.Teams[player.Team], true
+	}
+	if policy == UnassignedObservers {
+		return Team{Name: ObserversTeamName}, true
+	}
+	return
+}
+
+// RosterTeam is a team together with the players on it.
+type RosterTeam struct {
+	Team
+	Players []Player
+}
+
+// Roster groups the players by team, in the order the server listed them.
+// Players outside the listed teams are handled according to policy; with
+// UnassignedObservers they make up a final ObserversTeamName team.
+func (s *GameServerStatus) Roster(policy UnassignedPolicy) (roster []RosterTeam) {
+	roster = make([]RosterTeam, len(s.Teams))
+	for i, team := range s.Teams {
+		roster[i].Team = team
+	}
+
+	var observers []Player
+	for _, player := range s.Players {
+		if int(player.Team) < len(s.Teams) {
+			roster[player.Team].Players = append(roster[player.Team].Players, player)
+		} else if _, ok := s.PlayerTeam(player, policy); ok {
+			observers = append(observers, player)
+		}
+	}
+
+	if len(observers) > 0 {
+		roster = append(roster, RosterTeam{Team: Team{Name: ObserversTeamName}, Players: observers})
+	}
+	return
+}
+
+func (g *GameServer) Roster(policy UnassignedPolicy) (roster []RosterTeam) {
+	status := g.Status()
+	return status.Roster(policy)
+}
+
+// PlayerLocation is a player found on a server by FindPlayer.
+type PlayerLocation struct {
+	Address    string
+	ServerName string
+	Player     Player
+}
+
+// NormalizePlayerName returns the form of name used to compare player
+// names: surrounding space removed and case folded.
+func NormalizePlayerName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// FindPlayer returns every player in statuses whose normalized name matches
+// the normalized name.
+func FindPlayer(name string, statuses []GameServerStatus) (found []PlayerLocation) {
+	name = NormalizePlayerName(name)
+	for i := range statuses {
+		for _, player := range statuses[i].Players {
+			if NormalizePlayerName(player.Name) == name {
+				found = append(found, PlayerLocation{Address: statuses[i].Address, ServerName: statuses[i].Name, Player: player})
+			}
+		}
+	}
+	return
+}
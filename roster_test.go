@@ -0,0 +1,91 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import "testing"
+
+func TestGameServerStatusPlayerTeam(t *testing.T) {
+	status := GameServerStatus{
+		Teams: []Team{{Name: "Blood Eagle", Score: "3"}, {Name: "Diamond Sword", Score: "1"}},
+	}
+
+	team, ok := status.PlayerTeam(Player{Team: 1}, UnassignedDrop)
+	if !ok || team.Name != "Diamond Sword" {
+		t.Errorf("team 1: %v, %t", team, ok)
+	}
+
+	team, ok = status.PlayerTeam(Player{Team: 255}, UnassignedObservers)
+	if !ok || team.Name != ObserversTeamName {
+		t.Errorf("team 255 with UnassignedObservers: %v, %t", team, ok)
+	}
+
+	_, ok = status.PlayerTeam(Player{Team: 255}, UnassignedDrop)
+	if ok {
+		t.Error("team 255 with UnassignedDrop was not dropped")
+	}
+}
+
+func TestFindPlayer(t *testing.T) {
+	statuses := []GameServerStatus{
+		{Address: "1.2.3.4:28001", Name: "One", Players: []Player{{Name: "Alpha"}, {Name: "Beta"}}},
+		{Address: "5.6.7.8:28001", Name: "Two", Players: []Player{{Name: " alpha "}}},
+		{Address: "9.9.9.9:28001", Name: "Three"},
+	}
+
+	found := FindPlayer("ALPHA", statuses)
+	if len(found) != 2 {
+		t.Fatalf("len(found): %d != 2: %v", len(found), found)
+	}
+	if found[0].Address != "1.2.3.4:28001" || found[1].ServerName != "Two" {
+		t.Errorf("found: %v", found)
+	}
+
+	if found = FindPlayer("Gamma", statuses); found != nil {
+		t.Errorf("found missing player: %v", found)
+	}
+}
+
+func TestGameServerStatusRoster(t *testing.T) {
+	status := GameServerStatus{
+		Teams: []Team{{Name: "Blood Eagle"}, {Name: "Diamond Sword"}, {Name: "Children of the Phoenix"}},
+		Players: []Player{
+			{Name: "Alpha", Team: 1},
+			{Name: "Beta", Team: 0},
+			{Name: "Gamma", Team: 255},
+			{Name: "Delta", Team: 1},
+		},
+	}
+
+	roster := status.Roster(UnassignedObservers)
+	if len(roster) != 4 {
+		t.Fatalf("len(roster): %d != 4", len(roster))
+	}
+	if roster[1].Name != "Diamond Sword" || len(roster[1].Players) != 2 || roster[1].Players[1].Name != "Delta" {
+		t.Errorf("roster[1]: %v", roster[1])
+	}
+	if len(roster[2].Players) != 0 {
+		t.Errorf("roster[2]: %v", roster[2])
+	}
+	if roster[3].Name != ObserversTeamName || len(roster[3].Players) != 1 {
+		t.Errorf("roster[3]: %v", roster[3])
+	}
+
+	roster = status.Roster(UnassignedDrop)
+	if len(roster) != 3 {
+		t.Errorf("len(roster) with UnassignedDrop: %d != 3", len(roster))
+	}
+}
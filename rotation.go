@@ -0,0 +1,186 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"sort"
+	"time"
+)
+
+// RotationEntry is one mission of an inferred map rotation.
+type RotationEntry struct {
+	Mission string
+	// Duration is the median time the mission was played for, or zero if it
+	// was never seen from start to finish.
+	Duration time.Duration
+	// Plays is the number of times the mission was seen being played.
+	Plays int
+}
+
+// Rotation is a map rotation in play order.
+type Rotation []RotationEntry
+
+// Next returns the entry following mission in the rotation, wrapping around
+// at the end.
+func (r Rotation) Next(mission string) (entry RotationEntry, ok bool) {
+	for i := range r {
+		if r[i].Mission == mission {
+			return r[(i+1)%len(r)], true
+		}
+	}
+	return
+}
+
+// missionRun is a stretch of consecutive statuses showing the same mission.
+type missionRun struct {
+	mission string
+	start   time.Time
+	// statuses are the statuses making up the run.
+	statuses []GameServerStatus
+}
+
+// missionRuns splits history, which must be in QueryTime order, into runs of
+// the same mission.
+func missionRuns(history []GameServerStatus) (runs []missionRun) {
+	first := 0
+	for i := range history {
+		if i+1 == len(history) || history[i+1].Mission != history[i].Mission {
+			runs = append(runs, missionRun{mission: history[first].Mission, start: history[first].QueryTime, statuses: history[first : i+1]})
+			first = i + 1
+		}
+	}
+	return
+}
+
+// InferRotation works out a server's map rotation from its statuses, which
+// must be in QueryTime order.  A mission is considered played from the first
+// status showing it until the first status showing a different mission.
+func InferRotation(history []GameServerStatus) (rotation Rotation) {
+	runs := missionRuns(history)
+	if len(runs) == 0 {
+		return
+	}
+
+	durations := make(map[string][]time.Duration)
+	plays := make(map[string]int)
+	successors := make(map[string]map[string]int)
+	var order []string
+	for i, r := range runs {
+		if plays[r.mission] == 0 {
+			order = append(order, r.mission)
+		}
+		plays[r.mission]++
+		if i+1 < len(runs) {
+			durations[r.mission] = append(durations[r.mission], runs[i+1].start.Sub(r.start))
+			if successors[r.mission] == nil {
+				successors[r.mission] = make(map[string]int)
+			}
+			successors[r.mission][runs[i+1].mission]++
+		}
+	}
+
+	// Follow the most common successor of each mission from the first one
+	// seen, then add any mission that chain did not reach.
+	added := make(map[string]bool)
+	add := func(mission string) {
+		added[mission] = true
+		entry := RotationEntry{Mission: mission, Plays: plays[mission]}
+		if d := durations[mission]; len(d) > 0 {
+			sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+			entry.Duration = d[len(d)/2]
+		}
+		rotation = append(rotation, entry)
+	}
+	for mission := order[0]; !added[mission]; {
+		add(mission)
+		next, best := "", 0
+		for _, candidate := range order {
+			if count := successors[mission][candidate]; count > best {
+				next, best = candidate, count
+			}
+		}
+		if best == 0 {
+			break
+		}
+		mission = next
+	}
+	for _, mission := range order {
+		if !added[mission] {
+			add(mission)
+		}
+	}
+	return
+}
+
+// MissionStats summarises how one mission was played across servers.
+type MissionStats struct {
+	Mission string
+	// Plays is the number of times the mission was seen being played.
+	Plays int
+	// AveragePlayers is the mean player count over every status showing
+	// the mission.
+	AveragePlayers float64
+	// AverageDuration is the mean time the mission was played for, counting
+	// only plays followed by another mission, or zero if there were none.
+	AverageDuration time.Duration
+}
+
+// AggregateMissions summarises every mission played in histories, each of
+// which must be one server's statuses in QueryTime order, the way
+// InferRotation reads them.  The result is sorted by plays, most played
+// first, then by mission name.
+func AggregateMissions(histories ...[]GameServerStatus) (stats []MissionStats) {
+	type totals struct {
+		plays, statuses, players, durations int
+		duration                            time.Duration
+	}
+	byMission := make(map[string]*totals)
+	for _, history := range histories {
+		runs := missionRuns(history)
+		for i, r := range runs {
+			t := byMission[r.mission]
+			if t == nil {
+				t = &totals{}
+				byMission[r.mission] = t
+			}
+			t.plays++
+			for _, status := range r.statuses {
+				t.statuses++
+				t.players += int(status.NumPlayers)
+			}
+			if i+1 < len(runs) {
+				t.durations++
+				t.duration += runs[i+1].start.Sub(r.start)
+			}
+		}
+	}
+
+	for mission, t := range byMission {
+		entry := MissionStats{Mission: mission, Plays: t.plays, AveragePlayers: float64(t.players) / float64(t.statuses)}
+		if t.durations > 0 {
+			entry.AverageDuration = t.duration / time.Duration(t.durations)
+		}
+		stats = append(stats, entry)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Plays != stats[j].Plays {
+			return stats[i].Plays > stats[j].Plays
+		}
+		return stats[i].Mission < stats[j].Mission
+	})
+	return
+}
@@ -0,0 +1,99 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInferRotation(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	var history []GameServerStatus
+	missions := []string{"Raindance", "Raindance", "Broadside", "Broadside", "Broadside", "Sanctuary", "Raindance", "Raindance", "Broadside"}
+	for i, mission := range missions {
+		history = append(history, GameServerStatus{Mission: mission, QueryTime: start.Add(time.Duration(i) * 10 * time.Minute)})
+	}
+
+	rotation := InferRotation(history)
+	expected := []string{"Raindance", "Broadside", "Sanctuary"}
+	if len(rotation) != len(expected) {
+		t.Fatalf("InferRotation(): %v", rotation)
+	}
+	for i := range expected {
+		if rotation[i].Mission != expected[i] {
+			t.Fatalf("InferRotation(): %v", rotation)
+		}
+	}
+	if rotation[0].Duration != 20*time.Minute || rotation[0].Plays != 2 {
+		t.Errorf("rotation[0]: %v", rotation[0])
+	}
+	if rotation[1].Duration != 30*time.Minute || rotation[1].Plays != 2 {
+		t.Errorf("rotation[1]: %v", rotation[1])
+	}
+
+	next, ok := rotation.Next("Sanctuary")
+	if !ok || next.Mission != "Raindance" {
+		t.Errorf("rotation.Next(Sanctuary): %v, %t", next, ok)
+	}
+	_, ok = rotation.Next("Unknown")
+	if ok {
+		t.Error("rotation.Next(Unknown) found a mission")
+	}
+
+	if InferRotation(nil) != nil {
+		t.Error("InferRotation(nil) != nil")
+	}
+}
+
+func TestAggregateMissions(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := func(polls ...GameServerStatus) []GameServerStatus {
+		for i := range polls {
+			polls[i].QueryTime = start.Add(time.Duration(i) * 10 * time.Minute)
+		}
+		return polls
+	}
+	stats := AggregateMissions(
+		history(
+			GameServerStatus{Mission: "Raindance", NumPlayers: 10},
+			GameServerStatus{Mission: "Raindance", NumPlayers: 20},
+			GameServerStatus{Mission: "Broadside", NumPlayers: 4},
+			GameServerStatus{Mission: "Raindance", NumPlayers: 6},
+		),
+		history(
+			GameServerStatus{Mission: "Broadside", NumPlayers: 8},
+			GameServerStatus{Mission: "Broadside", NumPlayers: 8},
+			GameServerStatus{Mission: "Broadside", NumPlayers: 8},
+			GameServerStatus{Mission: "Sanctuary", NumPlayers: 2},
+		),
+	)
+
+	if len(stats) != 3 {
+		t.Fatalf("AggregateMissions(): %v", stats)
+	}
+	expected := []MissionStats{
+		{Mission: "Broadside", Plays: 2, AveragePlayers: 7, AverageDuration: 20 * time.Minute},
+		{Mission: "Raindance", Plays: 2, AveragePlayers: 12, AverageDuration: 20 * time.Minute},
+		{Mission: "Sanctuary", Plays: 1, AveragePlayers: 2},
+	}
+	for i := range expected {
+		if stats[i] != expected[i] {
+			t.Errorf("stats[%d]: %v != %v", i, stats[i], expected[i])
+		}
+	}
+}
@@ -0,0 +1,81 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RPGServerType is the server type reported by the tribesrp/RPG mod.
+const RPGServerType = "tribesrp"
+
+// RPGScore is a player score string from the RPG mod decoded into its
+// columns.
+type RPGScore struct {
+	Name   string
+	Zone   string
+	Level  int
+	Status string
+}
+
+func IsRPGServer(serverType string) bool {
+	return strings.EqualFold(serverType, RPGServerType)
+}
+
+// ParseRPGScore decodes an RPG mod player score of the form
+// "Name\tZone\tLevel\tStatus".
+func ParseRPGScore(score string) (rpg RPGScore, err error) {
+	fields := strings.Split(score, "\t")
+	if len(fields) != 4 {
+		err = fmt.Errorf("t1net.ParseRPGScore: Expected 4 fields, got %d", len(fields))
+		return
+	}
+
+	rpg.Level, err = strconv.Atoi(strings.TrimSpace(fields[2]))
+	if err != nil {
+		err = fmt.Errorf("t1net.ParseRPGScore: Invalid level: %w", err)
+		return
+	}
+	rpg.Name = strings.TrimSpace(fields[0])
+	rpg.Zone = strings.TrimSpace(fields[1])
+	rpg.Status = strings.TrimSpace(fields[3])
+	return
+}
+
+// RPGScores decodes the score of every player, in the same order as Players.
+// It returns nil if the server is not running the RPG mod.
+func (s *GameServerStatus) RPGScores() (scores []RPGScore, err error) {
+	if !IsRPGServer(s.ServerType) {
+		return
+	}
+
+	scores = make([]RPGScore, len(s.Players))
+	for i, player := range s.Players {
+		scores[i], err = ParseRPGScore(player.Score)
+		if err != nil {
+			return nil, fmt.Errorf("t1net.GameServerStatus.RPGScores: Player %q: %w", player.Name, err)
+		}
+	}
+	return
+}
+
+func (g *GameServer) RPGScores() (scores []RPGScore, err error) {
+	status := g.Status()
+	return status.RPGScores()
+}
@@ -0,0 +1,65 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import "testing"
+
+func TestParseRPGScore(t *testing.T) {
+	rpg, err := ParseRPGScore("td\tOld Jaten Outpost\t134\tidle   ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := RPGScore{Name: "td", Zone: "Old Jaten Outpost", Level: 134, Status: "idle"}
+	if rpg != expected {
+		t.Fatalf("%v != %v", rpg, expected)
+	}
+
+	_, err = ParseRPGScore("td\t10")
+	if err == nil {
+		t.Fatal("expected an error for a short score")
+	}
+	_, err = ParseRPGScore("td\tzone\tten\tidle")
+	if err == nil {
+		t.Fatal("expected an error for a non numeric level")
+	}
+}
+
+func TestGameServerStatusRPGScores(t *testing.T) {
+	status := GameServerStatus{
+		ServerType: "tribesrp",
+		Players: []Player{
+			{Name: "td", Score: "td\tOld Jaten Outpost\t134\tidle   "},
+			{Name: "phantom", Score: "phantom\tKeldrin Town\t2\tidle     "},
+		},
+	}
+	scores, err := status.RPGScores()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scores) != 2 {
+		t.Fatalf("len(scores): %d != 2", len(scores))
+	}
+	if scores[1].Zone != "Keldrin Town" || scores[1].Level != 2 {
+		t.Errorf("scores[1]: %v", scores[1])
+	}
+
+	status.ServerType = "CTF"
+	scores, err = status.RPGScores()
+	if err != nil || scores != nil {
+		t.Errorf("non RPG server: %v, %v", scores, err)
+	}
+}
@@ -0,0 +1,107 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReadServerList reads game server addresses from r in the formats older
+// monitoring scripts keep them in, so their lists can be queried as is:
+//
+//   - one "host:port" or "host" per line, the port defaulting to
+//     DefaultGamePort;
+//   - qstat server files, with lines such as "TBS host:port" or
+//     "-tbs host:port";
+//   - qstat output, where the address is the first column, and qstat -raw
+//     output with any single character delimiter.
+//
+// Blank lines and anything after a '#' are ignored, as are headers, player
+// lines and entries qstat lists for other games.  Addresses are normalized
+// with NormalizeAddress and returned once each, in the order first seen.  An
+// address that cannot be normalized is reported with its line number.
+func ReadServerList(r io.Reader) (servers []string, err error) {
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	// rawPlayers is set after a qstat -raw server line, whose player lines
+	// run until the next blank line.
+	rawPlayers, ok := false, false
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		if len(strings.TrimSpace(text)) == 0 {
+			rawPlayers = false
+			continue
+		}
+		if rawPlayers || text[0] == ' ' || text[0] == '\t' {
+			continue
+		}
+
+		var address string
+		if delimiter, raw := qstatRawDelimiter(text); raw {
+			address = strings.Split(text, delimiter)[1]
+			rawPlayers = true
+		} else if address, ok = serverListAddress(text); !ok {
+			continue
+		}
+
+		address, err = NormalizeAddress(address, DefaultGamePort)
+		if err != nil {
+			return nil, fmt.Errorf("t1net.ReadServerList: Line %d: %v", line, err)
+		}
+		if !seen[address] {
+			seen[address] = true
+			servers = append(servers, address)
+		}
+	}
+	return servers, scanner.Err()
+}
+
+// serverListAddress returns the address on a line of a plain list, a qstat
+// server file or qstat output.  ok is false for lines naming another game
+// and for qstat's header.
+func serverListAddress(text string) (address string, ok bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 1 || strings.ContainsAny(fields[0], ".:") {
+		return fields[0], true
+	}
+	// The first field is a qstat server type.
+	if strings.EqualFold(strings.TrimPrefix(fields[0], "-"), QStatType) {
+		return fields[1], true
+	}
+	return
+}
+
+// qstatRawDelimiter reports whether text is a Tribes server line of qstat
+// -raw output, and returns its delimiter.
+func qstatRawDelimiter(text string) (delimiter string, raw bool) {
+	rest := strings.TrimPrefix(text, QStatType)
+	if len(rest) == len(text) || len(rest) == 0 {
+		return
+	}
+	c := rest[0]
+	if c == ' ' || c == '\t' || c == '.' || c == ':' || c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' {
+		return
+	}
+	delimiter = rest[:1]
+	return delimiter, strings.Count(rest, delimiter) >= 2
+}
@@ -0,0 +1,60 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadServerList(t *testing.T) {
+	list := `# Favourites
+1.2.3.4:28001
+Tribes.Example.com   # default port
+
+TBS 5.6.7.8:28002
+-tbs 9.9.9.9
+Q3S 10.0.0.1:27960
+ADDRESS                PLAYERS          MAP RESPONSE TIME  NAME
+11.11.11.11:28001        2/32    Raindance        42 / 0  Alpha
+	   10 score   50ms Blood Eagle  One
+TBS;12.12.12.12:28001;Bravo;Broadside;32;1;60;0
+13.13.13.13:28001;5;Blood Eagle;60
+
+1.2.3.4:28001
+`
+	servers, err := ReadServerList(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"1.2.3.4:28001",
+		"tribes.example.com:28001",
+		"5.6.7.8:28002",
+		"9.9.9.9:28001",
+		"11.11.11.11:28001",
+		"12.12.12.12:28001",
+	}
+	if strings.Join(servers, " ") != strings.Join(expected, " ") {
+		t.Errorf("ReadServerList(): %v != %v", servers, expected)
+	}
+
+	_, err = ReadServerList(strings.NewReader("1.2.3.4:28001\n1.2.3.4:99999\n"))
+	if err == nil || !strings.Contains(err.Error(), "Line 2") {
+		t.Errorf("ReadServerList(): %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Simulation stands in for the network: queries made with WithSimulation are
+// answered from the statuses it holds instead of over UDP, so a
+// ServerBrowser and the code built on it can be developed and demonstrated
+// offline.  Replies go through the same encoding and parsing as real ones.
+// Servers it has no status for time out.
+type Simulation struct {
+	mutex   sync.RWMutex
+	masters map[string]MasterServerStatus
+	servers map[string]GameServerStatus
+	ping    time.Duration
+	update  func(address string, status *GameServerStatus)
+}
+
+// SetMaster makes the master server at address answer with status.
+func (s *Simulation) SetMaster(address string, status MasterServerStatus) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	status.Servers = append([]string(nil), status.Servers...)
+	s.masters[normalizeOrKeep(address, DefaultMasterPort)] = status
+}
+
+// SetServer makes the game server at address answer with status.  Servers
+// added this way are not listed by any master unless SetMaster lists them.
+func (s *Simulation) SetServer(address string, status GameServerStatus) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.servers[normalizeOrKeep(address, DefaultGamePort)] = copyStatus(status)
+}
+
+// RemoveServer makes the game server at address stop answering.
+func (s *Simulation) RemoveServer(address string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.servers, normalizeOrKeep(address, DefaultGamePort))
+}
+
+// SetPing delays every reply by ping, which is reported as its round trip
+// time.
+func (s *Simulation) SetPing(ping time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.ping = ping
+}
+
+// SetUpdate makes update change a server's status before each reply, so the
+// data moves between refreshes: players joining and leaving, scores
+// changing, missions rotating.  The changes are kept for the next reply.
+// It is called with the simulation's lock held, so it must not call its
+// methods.  A nil update leaves statuses as they were set.
+func (s *Simulation) SetUpdate(update func(address string, status *GameServerStatus)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.update = update
+}
+
+// LoadServers adds the game servers in a JSON fixture, an array of
+// GameServerStatus such as a recorded []GameServerStatus encoded with
+// encoding/json.  Each is keyed by its Address.
+func (s *Simulation) LoadServers(r io.Reader) (err error) {
+	var statuses []GameServerStatus
+	err = json.NewDecoder(r).Decode(&statuses)
+	if err != nil {
+		return
+	}
+	for _, status := range statuses {
+		s.SetServer(status.Address, status)
+	}
+	return
+}
+
+// gameReply returns the reply of the game server at address to a query
+// with key, after the simulated round trip time.
+func (s *Simulation) gameReply(ctx context.Context, address string, key uint16, timeout time.Duration) (reply []byte, ping time.Duration, err error) {
+	s.mutex.Lock()
+	status, ok := s.servers[address]
+	if ok && s.update != nil {
+		s.update(address, &status)
+		s.servers[address] = copyStatus(status)
+	}
+	ping = s.ping
+	s.mutex.Unlock()
+
+	if err = s.wait(ctx, ok, ping, timeout); err != nil {
+		return
+	}
+	var buffer bytes.Buffer
+	err = status.WriteReply(&buffer, key)
+	return buffer.Bytes(), ping, err
+}
+
+// masterReply returns the reply packets of the master server at address to
+// a query with key, after the simulated round trip time.
+func (s *Simulation) masterReply(ctx context.Context, address string, key uint16, timeout time.Duration) (packets [][]byte, ping time.Duration, err error) {
+	s.mutex.RLock()
+	status, ok := s.masters[address]
+	ping = s.ping
+	s.mutex.RUnlock()
+
+	if err = s.wait(ctx, ok, ping, timeout); err != nil {
+		return
+	}
+	packets, err = status.WriteReply(key)
+	return
+}
+
+// wait waits out ping, or the whole timeout if the server does not answer,
+// returning the error a real query would.
+func (s *Simulation) wait(ctx context.Context, answers bool, ping, timeout time.Duration) error {
+	answered := answers && ping < timeout
+	if !answered {
+		ping = timeout
+	}
+	timer := time.NewTimer(ping)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+	if !answered {
+		return os.ErrDeadlineExceeded
+	}
+	return nil
+}
+
+// NewSimulation returns a Simulation with no servers.
+func NewSimulation() *Simulation {
+	return &Simulation{
+		masters: make(map[string]MasterServerStatus),
+		servers: make(map[string]GameServerStatus),
+	}
+}
@@ -0,0 +1,135 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSimulation(t *testing.T) {
+	s := NewSimulation()
+	s.SetMaster("master.example:28000", MasterServerStatus{
+		Name:    "Simulated",
+		MOTD:    "Offline",
+		Servers: []string{"10.0.0.1:28001", "10.0.0.2:28001"},
+	})
+	s.SetServer("10.0.0.1:28001", GameServerStatus{
+		Name:       "Alpha",
+		Game:       "Tribes",
+		Version:    "1.41",
+		MaxPlayers: 32,
+		Mission:    "Raindance",
+	})
+	s.SetUpdate(func(address string, status *GameServerStatus) {
+		status.Players = append(status.Players, Player{Name: fmt.Sprint("Player", len(status.Players))})
+	})
+	s.SetPing(time.Millisecond)
+
+	b := NewServerBrowser("master.example")
+	b.SetSimulation(s)
+	b.SetTimeout(50 * time.Millisecond)
+	for refresh := 1; refresh <= 2; refresh++ {
+		err := b.Refresh(context.Background())
+		if err != nil {
+			t.Fatalf("Refresh(): %v", err)
+		}
+		statuses := b.Statuses()
+		if len(statuses) != 1 {
+			t.Fatalf("len(Statuses()): %v != 1", len(statuses))
+		}
+		if statuses[0].Name != "Alpha" || statuses[0].Mission != "Raindance" {
+			t.Errorf("Statuses(): %+v", statuses[0])
+		}
+		if int(statuses[0].NumPlayers) != refresh {
+			t.Errorf("NumPlayers: %v != %v", statuses[0].NumPlayers, refresh)
+		}
+	}
+	if name := b.Masters()[0].Name(); name != "Simulated" {
+		t.Errorf("Master Name(): %v != Simulated", name)
+	}
+
+	err := b.GameServer("10.0.0.2:28001").LastError()
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("LastError(): %v", err)
+	}
+}
+
+func TestSimulationLoadServers(t *testing.T) {
+	s := NewSimulation()
+	err := s.LoadServers(strings.NewReader(`[{"Address": "10.0.0.3:28001", "Name": "Fixture", "Teams": [{"Name": "Blood Eagle"}]}]`))
+	if err != nil {
+		t.Fatalf("LoadServers(): %v", err)
+	}
+
+	g := NewGameServer("10.0.0.3")
+	err = g.QueryWith(context.Background(), WithSimulation(s))
+	if err != nil {
+		t.Fatalf("QueryWith(): %v", err)
+	}
+	if g.Name() != "Fixture" || g.NumTeams() != 1 || g.Teams()[0].Name != "Blood Eagle" {
+		t.Errorf("Status(): %+v", g.Status())
+	}
+}
+
+func TestMasterServerStatusWriteReply(t *testing.T) {
+	status := MasterServerStatus{Name: "Master", MOTD: "Welcome"}
+	for i := 0; i < 300; i++ {
+		status.Servers = append(status.Servers, fmt.Sprintf("10.0.%d.%d:28001", i/256, i%256))
+	}
+
+	packets, err := status.WriteReply(0x1234)
+	if err != nil {
+		t.Fatalf("WriteReply(): %v", err)
+	}
+	if len(packets) < 2 {
+		t.Errorf("len(WriteReply()): %v < 2", len(packets))
+	}
+	for _, packet := range packets {
+		if len(packet) > masterPacketSize {
+			t.Errorf("len(packet): %v > %v", len(packet), masterPacketSize)
+		}
+	}
+
+	m := NewMasterServer("master.example")
+	err = m.LoadReply(packets...)
+	if err != nil {
+		t.Fatalf("LoadReply(): %v", err)
+	}
+	servers := m.Servers()
+	if len(servers) != 300 || servers[299] != status.Servers[299] {
+		t.Errorf("Servers(): %v", servers)
+	}
+	if m.Name() != "Master" || m.MOTD() != "Welcome" {
+		t.Errorf("Name(), MOTD(): %v, %v", m.Name(), m.MOTD())
+	}
+
+	status.Servers = []string{"master.example:28001"}
+	if _, err = status.WriteReply(0); err == nil {
+		t.Errorf("WriteReply(): Hostname accepted")
+	}
+	status.Servers = nil
+	status.MOTD = strings.Repeat("x", 256)
+	if _, err = status.WriteReply(0); err == nil {
+		t.Errorf("WriteReply(): 256 byte MOTD accepted")
+	}
+}
@@ -0,0 +1,313 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SOCKS5 address types, from RFC 1928.
+const (
+	socks5IPv4   = 0x01
+	socks5Domain = 0x03
+	socks5IPv6   = 0x04
+)
+
+// SOCKS5Transport is a Transport relaying queries through a SOCKS5 proxy
+// with UDP ASSOCIATE (RFC 1928), for hosts whose only way out is a proxy.
+// Use it with WithTransport.  Fragmented datagrams are not supported and
+// are dropped.  Once the proxy ends the association, by closing the TCP
+// connection that requested it, Send and Receive fail with an error saying
+// so rather than queries timing out.
+type SOCKS5Transport struct {
+	// control is the TCP connection the association lives as long as.
+	control net.Conn
+	relay   *net.UDPConn
+	once    sync.Once
+
+	mutex  sync.Mutex
+	closed bool
+	// ended is set once the proxy has closed control.
+	ended error
+}
+
+// DialSOCKS5 connects to the SOCKS5 proxy at proxyAddress and asks it to
+// relay UDP.  username and password are sent if username is not empty
+// (RFC 1929), otherwise no authentication is offered.  ctx bounds the
+// handshake only.
+func DialSOCKS5(ctx context.Context, proxyAddress, username, password string) (t *SOCKS5Transport, err error) {
+	control, err := (&net.Dialer{}).DialContext(ctx, "tcp", proxyAddress)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = control.Close()
+		}
+	}()
+	defer stopOnDone(ctx, control, false)()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err = control.SetDeadline(deadline); err != nil {
+			return
+		}
+	}
+
+	relayAddr, err := socks5Associate(control, username, password)
+	if err != nil {
+		if ctxErr := contextDone(ctx); ctxErr != nil {
+			err = ctxErr
+		}
+		return
+	}
+	// A proxy listening on every address reports the relay as unspecified;
+	// it is then reached at the proxy's own address.
+	if relayAddr.IP.IsUnspecified() {
+		relayAddr.IP = control.RemoteAddr().(*net.TCPAddr).IP
+	}
+	relay, err := net.DialUDP("udp", nil, relayAddr)
+	if err != nil {
+		return
+	}
+	if err = control.SetDeadline(time.Time{}); err != nil {
+		_ = relay.Close()
+		return
+	}
+	t = &SOCKS5Transport{control: control, relay: relay}
+	go t.watch()
+	return
+}
+
+// watch waits for the proxy to close the control connection, which ends the
+// association, and then closes the relay socket so that a Receive in
+// progress returns.
+func (t *SOCKS5Transport) watch() {
+	// Nothing else is sent on the control connection once the association
+	// is set up.
+	_, err := io.Copy(io.Discard, t.control)
+	if err == nil {
+		err = io.EOF
+	}
+
+	t.mutex.Lock()
+	if !t.closed {
+		t.ended = fmt.Errorf("t1net.SOCKS5Transport: Proxy ended the association: %w", err)
+	}
+	t.mutex.Unlock()
+	_ = t.relay.Close()
+}
+
+// endedErr returns the error the association ended with, if the proxy ended
+// it.
+func (t *SOCKS5Transport) endedErr() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.ended
+}
+
+// socks5Associate authenticates on control and requests UDP ASSOCIATE,
+// returning the relay address the proxy replies with.
+func socks5Associate(control net.Conn, username, password string) (relayAddr *net.UDPAddr, err error) {
+	methods := []byte{0x05, 0x01, 0x00}
+	if len(username) != 0 {
+		methods = []byte{0x05, 0x01, 0x02}
+	}
+	if _, err = control.Write(methods); err != nil {
+		return
+	}
+	reply := make([]byte, 2)
+	if _, err = io.ReadFull(control, reply); err != nil {
+		return
+	}
+	if reply[0] != 0x05 {
+		return nil, fmt.Errorf("t1net.DialSOCKS5: Not a SOCKS5 proxy: version %d", reply[0])
+	}
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		if len(username) > 255 || len(password) > 255 {
+			return nil, errors.New("t1net.DialSOCKS5: Username or password longer than 255 bytes")
+		}
+		auth := []byte{0x01, byte(len(username))}
+		auth = append(auth, username...)
+		auth = append(auth, byte(len(password)))
+		auth = append(auth, password...)
+		if _, err = control.Write(auth); err != nil {
+			return
+		}
+		if _, err = io.ReadFull(control, reply); err != nil {
+			return
+		}
+		if reply[1] != 0x00 {
+			return nil, errors.New("t1net.DialSOCKS5: Authentication failed")
+		}
+	default:
+		return nil, fmt.Errorf("t1net.DialSOCKS5: No acceptable authentication method: %#x", reply[1])
+	}
+
+	// UDP ASSOCIATE from an address the client does not know yet.
+	if _, err = control.Write([]byte{0x05, 0x03, 0x00, socks5IPv4, 0, 0, 0, 0, 0, 0}); err != nil {
+		return
+	}
+	header := make([]byte, 3)
+	if _, err = io.ReadFull(control, header); err != nil {
+		return
+	}
+	if header[0] != 0x05 || header[1] != 0x00 {
+		return nil, fmt.Errorf("t1net.DialSOCKS5: Proxy refused UDP ASSOCIATE: reply %d", header[1])
+	}
+	host, port, err := readSOCKS5Address(control)
+	if err != nil {
+		return
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("t1net.DialSOCKS5: Relay address is not an IP address: %s", host)
+	}
+	return &net.UDPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readSOCKS5Address reads an ATYP, DST.ADDR and DST.PORT triple.
+func readSOCKS5Address(r io.Reader) (host string, port uint16, err error) {
+	atyp := make([]byte, 1)
+	if _, err = io.ReadFull(r, atyp); err != nil {
+		return
+	}
+	var addr []byte
+	switch atyp[0] {
+	case socks5IPv4:
+		addr = make([]byte, net.IPv4len)
+	case socks5IPv6:
+		addr = make([]byte, net.IPv6len)
+	case socks5Domain:
+		length := make([]byte, 1)
+		if _, err = io.ReadFull(r, length); err != nil {
+			return
+		}
+		addr = make([]byte, length[0])
+	default:
+		return "", 0, fmt.Errorf("t1net.readSOCKS5Address: Unknown address type %d", atyp[0])
+	}
+	if _, err = io.ReadFull(r, addr); err != nil {
+		return
+	}
+	if err = binary.Read(r, binary.BigEndian, &port); err != nil {
+		return
+	}
+	host = string(addr)
+	if atyp[0] != socks5Domain {
+		host = net.IP(addr).String()
+	}
+	return
+}
+
+// writeSOCKS5Address writes address as an ATYP, DST.ADDR and DST.PORT
+// triple.
+func writeSOCKS5Address(buffer *bytes.Buffer, address string) (err error) {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return
+	}
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil {
+		return fmt.Errorf("t1net.writeSOCKS5Address: Invalid port %q", portString)
+	}
+	ip := net.ParseIP(host)
+	switch {
+	case ip.To4() != nil:
+		buffer.WriteByte(socks5IPv4)
+		buffer.Write(ip.To4())
+	case ip != nil:
+		buffer.WriteByte(socks5IPv6)
+		buffer.Write(ip.To16())
+	case len(host) <= 255:
+		buffer.WriteByte(socks5Domain)
+		buffer.WriteByte(byte(len(host)))
+		buffer.WriteString(host)
+	default:
+		return fmt.Errorf("t1net.writeSOCKS5Address: Host name too long: %s", host)
+	}
+	return binary.Write(buffer, binary.BigEndian, uint16(port))
+}
+
+// Send relays packet to address, an "ip:port" or "host:port" address
+// resolved by the proxy, through the proxy's relay.
+func (t *SOCKS5Transport) Send(packet []byte, address string) (err error) {
+	if err = t.endedErr(); err != nil {
+		return
+	}
+	var buffer bytes.Buffer
+	// RSV and FRAG
+	buffer.Write([]byte{0x00, 0x00, 0x00})
+	if err = writeSOCKS5Address(&buffer, address); err != nil {
+		return
+	}
+	buffer.Write(packet)
+	_, err = t.relay.Write(buffer.Bytes())
+	if endedErr := t.endedErr(); err != nil && endedErr != nil {
+		err = endedErr
+	}
+	return
+}
+
+// Receive reads the next datagram relayed by the proxy into packet and
+// returns its length and the address it came from, as reported by the
+// proxy.  It waits until ctx is done, returning ctx.Err().
+func (t *SOCKS5Transport) Receive(ctx context.Context, packet []byte) (n int, address string, err error) {
+	readBuffer := make([]byte, 262+len(packet))
+	for {
+		n, _, err = readUDP(ctx, t.relay, readBuffer)
+		if err != nil {
+			if endedErr := t.endedErr(); endedErr != nil {
+				err = endedErr
+			}
+			return 0, "", err
+		}
+		// Fragments and malformed datagrams are dropped.
+		if n < 4 || readBuffer[2] != 0x00 {
+			continue
+		}
+		reader := bytes.NewReader(readBuffer[3:n])
+		host, port, addrErr := readSOCKS5Address(reader)
+		if addrErr != nil {
+			continue
+		}
+		return copy(packet, readBuffer[n-reader.Len():n]), net.JoinHostPort(host, strconv.Itoa(int(port))), nil
+	}
+}
+
+// Close ends the association and closes the relay socket.
+func (t *SOCKS5Transport) Close() (err error) {
+	t.mutex.Lock()
+	t.closed = true
+	t.mutex.Unlock()
+	t.once.Do(func() {
+		err = t.relay.Close()
+		if controlErr := t.control.Close(); err == nil {
+			err = controlErr
+		}
+	})
+	return
+}
@@ -0,0 +1,185 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveSOCKS5 runs a SOCKS5 proxy on a system chosen port that accepts one
+// client with the given credentials and answers game server queries sent
+// through it with status itself, as the server at target.  closer also
+// ends the association.
+func serveSOCKS5(t *testing.T, username, password, target string, status GameServerStatus) (address string, closer func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+
+	go func() {
+		control, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer control.Close()
+		request := make([]byte, 3)
+		if _, err = io.ReadFull(control, request); err != nil || request[2] != 0x02 {
+			t.Errorf("SOCKS5 methods: %v, %v", request, err)
+			return
+		}
+		_, _ = control.Write([]byte{0x05, 0x02})
+		// The version byte comes before the username's length only.
+		var credentials [2]string
+		for i := range credentials {
+			length := make([]byte, 2)
+			if _, err = io.ReadFull(control, length[i:]); err != nil {
+				return
+			}
+			field := make([]byte, length[1])
+			if _, err = io.ReadFull(control, field); err != nil {
+				return
+			}
+			credentials[i] = string(field)
+		}
+		if credentials[0] != username || credentials[1] != password {
+			_, _ = control.Write([]byte{0x01, 0x01})
+			return
+		}
+		_, _ = control.Write([]byte{0x01, 0x00})
+		associate := make([]byte, 10)
+		if _, err = io.ReadFull(control, associate); err != nil || associate[1] != 0x03 {
+			t.Errorf("SOCKS5 request: %v, %v", associate, err)
+			return
+		}
+		port := relay.LocalAddr().(*net.UDPAddr).Port
+		_, _ = control.Write([]byte{0x05, 0x00, 0x00, socks5IPv4, 0, 0, 0, 0, byte(port >> 8), byte(port)})
+		// The association lasts until either end closes the connection.
+		go func() {
+			<-done
+			_ = control.Close()
+		}()
+		_, _ = io.Copy(io.Discard, control)
+	}()
+
+	go func() {
+		readBuffer := make([]byte, 512)
+		for {
+			n, addr, err := relay.ReadFromUDP(readBuffer)
+			if err != nil {
+				return
+			}
+			reader := bytes.NewReader(readBuffer[3:n])
+			host, port, err := readSOCKS5Address(reader)
+			if err != nil || net.JoinHostPort(host, fmt.Sprint(port)) != target {
+				continue
+			}
+			query := readBuffer[n-reader.Len() : n]
+			var reply bytes.Buffer
+			reply.Write(readBuffer[0 : n-reader.Len()])
+			if err = status.WriteReply(&reply, binary.BigEndian.Uint16(query[1:3])); err != nil {
+				t.Error(err)
+			}
+			_, _ = relay.WriteToUDP(reply.Bytes(), addr)
+		}
+	}()
+
+	return listener.Addr().String(), func() {
+		close(done)
+		_ = listener.Close()
+		_ = relay.Close()
+	}
+}
+
+func TestSOCKS5Transport(t *testing.T) {
+	status := GameServerStatus{Name: "Proxied", Game: "Tribes", Version: "1.41"}
+	proxy, closer := serveSOCKS5(t, "user", "secret", "192.0.2.1:28001", status)
+	defer closer()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	transport, err := DialSOCKS5(ctx, proxy, "user", "secret")
+	if err != nil {
+		t.Fatalf("DialSOCKS5(): %v", err)
+	}
+	defer transport.Close()
+
+	game := NewGameServer("192.0.2.1")
+	err = game.QueryWith(context.Background(), WithTransport(transport), WithQueryTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("QueryWith(): %v", err)
+	}
+	if game.Name() != "Proxied" {
+		t.Errorf("Name(): %v != Proxied", game.Name())
+	}
+}
+
+func TestSOCKS5TransportEnded(t *testing.T) {
+	status := GameServerStatus{Name: "Proxied", Game: "Tribes", Version: "1.41"}
+	proxy, closer := serveSOCKS5(t, "user", "secret", "192.0.2.1:28001", status)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	transport, err := DialSOCKS5(ctx, proxy, "user", "secret")
+	if err != nil {
+		t.Fatalf("DialSOCKS5(): %v", err)
+	}
+	defer transport.Close()
+
+	received := make(chan error, 1)
+	go func() {
+		_, _, err := transport.Receive(context.Background(), make([]byte, 64))
+		received <- err
+	}()
+	closer()
+
+	select {
+	case err = <-received:
+		if err == nil || !strings.Contains(err.Error(), "ended the association") {
+			t.Errorf("Receive(): %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Receive() did not return once the proxy ended the association")
+	}
+	if err = transport.Send([]byte{0x62, 0, 0}, "192.0.2.1:28001"); err == nil {
+		t.Error("Send(): expected an error once the proxy ended the association")
+	}
+}
+
+func TestSOCKS5TransportBadPassword(t *testing.T) {
+	proxy, closer := serveSOCKS5(t, "user", "secret", "192.0.2.1:28001", GameServerStatus{})
+	defer closer()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := DialSOCKS5(ctx, proxy, "user", "wrong")
+	if err == nil {
+		t.Errorf("DialSOCKS5(): Wrong password accepted")
+	}
+}
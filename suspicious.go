@@ -0,0 +1,152 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	// suspiciousCopies is how many different IPs must report an identical
+	// status before they are flagged as copies.
+	suspiciousCopies = 3
+	// suspiciousPing is the round trip time below which a reply is
+	// considered too fast to have come from a real remote server.
+	suspiciousPing = time.Millisecond
+	// maxTribesPlayers is the most players a Tribes server supports.
+	maxTribesPlayers = 128
+	// suspiciousPlayerJump is how many players may join or leave between
+	// two consecutive statuses of a server, however close together.
+	suspiciousPlayerJump = 16
+	// suspiciousPlayerRate is how many more players per minute between two
+	// statuses may join or leave on top of suspiciousPlayerJump.
+	suspiciousPlayerRate = 8
+)
+
+// Suspicion is the evidence that a server in an aggregated list is fake.
+type Suspicion struct {
+	Address string
+	// Score is between 0 (no evidence) and 1 (almost certainly fake).
+	Score   float64
+	Reasons []string
+}
+
+// DetectSuspicious looks for signs of fake servers in statuses: identical
+// replies from several IPs, impossible player counts and replies that arrive
+// too fast to be real.  It returns one Suspicion per status, in order.
+func DetectSuspicious(statuses []GameServerStatus) (suspicions []Suspicion) {
+	payloads := make([][sha256.Size]byte, len(statuses))
+	hosts := make(map[[sha256.Size]byte]map[string]bool)
+	for i := range statuses {
+		var buffer bytes.Buffer
+		if statuses[i].WriteReply(&buffer, 0) != nil {
+			continue
+		}
+		payloads[i] = sha256.Sum256(buffer.Bytes())
+		host, _, err := net.SplitHostPort(statuses[i].Address)
+		if err != nil {
+			host = statuses[i].Address
+		}
+		if hosts[payloads[i]] == nil {
+			hosts[payloads[i]] = make(map[string]bool)
+		}
+		hosts[payloads[i]][host] = true
+	}
+
+	suspicions = make([]Suspicion, len(statuses))
+	for i := range statuses {
+		s := &statuses[i]
+		suspicion := &suspicions[i]
+		suspicion.Address = s.Address
+		clean := 1.0
+		flag := func(weight float64, reason string) {
+			clean *= 1 - weight
+			suspicion.Reasons = append(suspicion.Reasons, reason)
+		}
+
+		if copies := len(hosts[payloads[i]]); copies >= suspiciousCopies {
+			flag(0.6, fmt.Sprintf("identical reply from %d IPs", copies))
+		}
+		if s.NumPlayers > s.MaxPlayers || int(s.NumPlayers) > maxTribesPlayers || int(s.MaxPlayers) > maxTribesPlayers {
+			flag(0.5, fmt.Sprintf("impossible player count %d/%d", s.NumPlayers, s.MaxPlayers))
+		}
+		if int(s.NumPlayers) != len(s.Players) {
+			flag(0.3, fmt.Sprintf("player count %d does not match %d players listed", s.NumPlayers, len(s.Players)))
+		}
+		if !s.QueryTime.IsZero() && s.Ping < suspiciousPing && !isLoopback(s.Address) {
+			flag(0.3, fmt.Sprintf("reply in %s", s.Ping))
+		}
+
+		suspicion.Score = 1 - clean
+	}
+	return
+}
+
+// DetectJumps looks for implausible changes between consecutive statuses in
+// history, which must be one server's statuses in QueryTime order: the
+// player count jumping by more than could join or leave in the time between
+// them, and the name changing on consecutive statuses.  It returns one
+// Suspicion per status, in order, so stat pipelines can quarantine statuses
+// that are flagged.
+func DetectJumps(history []GameServerStatus) (suspicions []Suspicion) {
+	suspicions = make([]Suspicion, len(history))
+	for i := range history {
+		s := &history[i]
+		suspicion := &suspicions[i]
+		suspicion.Address = s.Address
+		if i == 0 {
+			continue
+		}
+		previous := &history[i-1]
+		clean := 1.0
+		flag := func(weight float64, reason string) {
+			clean *= 1 - weight
+			suspicion.Reasons = append(suspicion.Reasons, reason)
+		}
+
+		change := int(s.NumPlayers) - int(previous.NumPlayers)
+		if change < 0 {
+			change = -change
+		}
+		allowed := float64(suspiciousPlayerJump)
+		if elapsed := s.QueryTime.Sub(previous.QueryTime); elapsed > 0 {
+			allowed += elapsed.Minutes() * suspiciousPlayerRate
+		}
+		if float64(change) > allowed {
+			flag(0.5, fmt.Sprintf("player count changed from %d to %d in %s", previous.NumPlayers, s.NumPlayers, s.QueryTime.Sub(previous.QueryTime)))
+		}
+		if i >= 2 && s.Name != previous.Name && previous.Name != history[i-2].Name {
+			flag(0.4, fmt.Sprintf("name changed again from %q to %q", previous.Name, s.Name))
+		}
+
+		suspicion.Score = 1 - clean
+	}
+	return
+}
+
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,94 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetectSuspicious(t *testing.T) {
+	now := time.Now()
+	spam := GameServerStatus{Name: "Best Server", MaxPlayers: 32, Ping: 40 * time.Millisecond, QueryTime: now}
+	statuses := []GameServerStatus{
+		{Address: "1.1.1.1:28001", Name: "Real", MaxPlayers: 32, NumPlayers: 1, Players: []Player{{Name: "Alpha"}}, Ping: 50 * time.Millisecond, QueryTime: now},
+		{Address: "2.2.2.2:28001", Name: "Crowded", MaxPlayers: 200, NumPlayers: 0, Ping: 50 * time.Millisecond, QueryTime: now},
+		{Address: "3.3.3.3:28001", Name: "Instant", MaxPlayers: 32, Ping: 10 * time.Microsecond, QueryTime: now},
+	}
+	for _, address := range []string{"4.4.4.4:28001", "5.5.5.5:28001", "6.6.6.6:28001"} {
+		s := spam
+		s.Address = address
+		statuses = append(statuses, s)
+	}
+
+	suspicions := DetectSuspicious(statuses)
+	if len(suspicions) != len(statuses) {
+		t.Fatalf("len(suspicions): %d != %d", len(suspicions), len(statuses))
+	}
+	if suspicions[0].Score != 0 || suspicions[0].Reasons != nil {
+		t.Errorf("real server flagged: %v", suspicions[0])
+	}
+	for i := 1; i < len(suspicions); i++ {
+		if suspicions[i].Score <= 0 || len(suspicions[i].Reasons) != 1 {
+			t.Errorf("suspicions[%d] not flagged once: %v", i, suspicions[i])
+		}
+		if suspicions[i].Address != statuses[i].Address {
+			t.Errorf("suspicions[%d].Address: %s != %s", i, suspicions[i].Address, statuses[i].Address)
+		}
+	}
+}
+
+func TestDetectJumps(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	var history []GameServerStatus
+	polls := []struct {
+		name       string
+		numPlayers uint8
+	}{
+		{"Server", 0}, {"Server", 4}, {"Server", 96}, {"Server", 0}, {"Server", 10},
+		{"Server A", 10}, {"Server B", 10}, {"Server B", 10},
+	}
+	for i, poll := range polls {
+		history = append(history, GameServerStatus{
+			Address:    "1.1.1.1:28001",
+			Name:       poll.name,
+			NumPlayers: poll.numPlayers,
+			QueryTime:  start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	suspicions := DetectJumps(history)
+	if len(suspicions) != len(history) {
+		t.Fatalf("len(suspicions): %d != %d", len(suspicions), len(history))
+	}
+	flagged := []bool{false, false, true, true, false, false, true, false}
+	for i := range suspicions {
+		if (suspicions[i].Score > 0) != flagged[i] || (len(suspicions[i].Reasons) == 1) != flagged[i] {
+			t.Errorf("suspicions[%d]: %v", i, suspicions[i])
+		}
+	}
+
+	// The same jump is plausible over a long enough time.
+	history[2].QueryTime = history[1].QueryTime.Add(time.Hour)
+	if suspicions = DetectJumps(history[0:3]); suspicions[2].Score != 0 {
+		t.Errorf("suspicions[2]: %v", suspicions[2])
+	}
+
+	if len(DetectJumps(nil)) != 0 {
+		t.Error("DetectJumps(nil) returned suspicions")
+	}
+}
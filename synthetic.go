@@ -0,0 +1,207 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+)
+
+var (
+	syntheticPrefixes = []string{"", "", "[HvC] ", "|BoW| ", "{DS} ", "=NF= ", "Official ", "24/7 "}
+	syntheticNames    = []string{"Raindance", "Midnight", "Frontier", "Eagle's Nest", "Dropship", "Havoc", "Outpost", "Plasma Pit", "Starwolf Den", "Last Stand"}
+	syntheticSuffixes = []string{"", "", " CTF", " Pub", " Scrims", " - All Welcome", " #2", " [EU]", " [US West]"}
+
+	syntheticMods = []struct {
+		mod, serverType string
+		missions        []string
+	}{
+		{"base", "CTF", []string{"Raindance", "Broadside", "Stonehenge", "IceRidge", "DangerousCrossing"}},
+		{"base", "Capture and Hold", []string{"Avalanche", "Sanctuary", "Snowblind"}},
+		{"Renegades", "CTF", []string{"Raindance", "Crossfire", "Scarabrae", "Hildebrand"}},
+		{"Annihilation", "Deathmatch", []string{"Yin Yang", "Death Row", "Desert of Death"}},
+		{"Shifter", "CTF", []string{"Raindance", "Lakefront", "Broadside"}},
+	}
+
+	syntheticTeams   = []string{"Blood Eagle", "Diamond Sword", "Children of the Phoenix", "Starwolf"}
+	syntheticTags    = []string{"", "", "", "[HvC]", "|BoW|", "{DS}", "=NF=", "-SNC-"}
+	syntheticPlayers = []string{"Alpha", "Bravo", "Juggernaut", "Scout", "Mortar", "Sniper", "Cappy", "Heavy", "Ninja", "Goose", "Rook", "Vex", "Flak", "Spinfusor", "Chaingun", "Lag"}
+)
+
+// Generator produces plausible random game server statuses, and the replies
+// that encode them, for exercising browsers, exporters and daemons at a
+// scale no test network offers.  A Generator created with the same seed
+// produces the same data.  It is safe for concurrent use.
+type Generator struct {
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+// Status returns a random status for a game server at address, with a name,
+// mod, mission, teams and a roster of players with scores.
+func (g *Generator) Status(address string) (status GameServerStatus) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	mod := syntheticMods[g.rand.Intn(len(syntheticMods))]
+	status = GameServerStatus{
+		Address:           address,
+		Name:              g.pick(syntheticPrefixes) + g.pick(syntheticNames) + g.pick(syntheticSuffixes),
+		Game:              "Tribes",
+		Version:           "1.41",
+		Dedicated:         g.rand.Intn(10) > 0,
+		Password:          g.rand.Intn(10) == 0,
+		MaxPlayers:        uint8(8 * (1 + g.rand.Intn(4))),
+		CPUSpeed:          uint16(500 + 100*g.rand.Intn(30)),
+		Mod:               mod.mod,
+		ServerType:        mod.serverType,
+		Mission:           g.pick(mod.missions),
+		Info:              "Synthetic server",
+		TeamScoreHeader:   "Team\tScore",
+		PlayerScoreHeader: "Name\tScore",
+	}
+
+	teams := 2
+	if mod.serverType == "Deathmatch" {
+		teams = 1
+	}
+	for i := 0; i < teams; i++ {
+		status.Teams = append(status.Teams, Team{Name: syntheticTeams[i], Score: strconv.Itoa(g.rand.Intn(8))})
+	}
+
+	players := g.rand.Intn(int(status.MaxPlayers) + 1)
+	for i := 0; i < players; i++ {
+		status.Players = append(status.Players, g.player(i, teams))
+	}
+	status.NumTeams = uint8(len(status.Teams))
+	status.NumPlayers = uint8(len(status.Players))
+	return
+}
+
+// Statuses returns count random statuses for servers at distinct private
+// addresses, 10.0.0.1:28001 onwards.
+func (g *Generator) Statuses(count int) (statuses []GameServerStatus) {
+	statuses = make([]GameServerStatus, count)
+	for i := range statuses {
+		n := i + 1
+		statuses[i] = g.Status(fmt.Sprintf("10.%d.%d.%d:%d", n>>16&0xFF, n>>8&0xFF, n&0xFF, DefaultGamePort))
+	}
+	return
+}
+
+// Replies returns count random statuses and the reply packet encoding each,
+// as a game server would send it for key.
+func (g *Generator) Replies(count int, key uint16) (statuses []GameServerStatus, replies [][]byte, err error) {
+	statuses = g.Statuses(count)
+	replies = make([][]byte, count)
+	for i := range statuses {
+		var buffer bytes.Buffer
+		err = statuses[i].WriteReply(&buffer, key)
+		if err != nil {
+			return
+		}
+		replies[i] = buffer.Bytes()
+	}
+	return
+}
+
+// Update changes status the way a running game would between queries:
+// players join, leave and score, and the mission occasionally changes.  Its
+// signature matches Simulation.SetUpdate.
+func (g *Generator) Update(address string, status *GameServerStatus) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	teams := len(status.Teams)
+	if teams == 0 {
+		teams = 1
+	}
+	switch n := g.rand.Intn(4); {
+	case n == 0 && len(status.Players) < int(status.MaxPlayers):
+		status.Players = append(status.Players, g.player(len(status.Players), teams))
+	case n == 1 && len(status.Players) > 0:
+		i := g.rand.Intn(len(status.Players))
+		status.Players = append(status.Players[:i:i], status.Players[i+1:]...)
+	}
+	for i := range status.Players {
+		if g.rand.Intn(3) == 0 {
+			score, _ := strconv.Atoi(status.Players[i].Score)
+			status.Players[i].Score = strconv.Itoa(score + 1 + g.rand.Intn(5))
+		}
+		status.Players[i].Ping = uint8(20 + g.rand.Intn(180))
+	}
+	if g.rand.Intn(20) == 0 {
+		for _, mod := range syntheticMods {
+			if mod.mod == status.Mod && mod.serverType == status.ServerType {
+				status.Mission = g.pick(mod.missions)
+				for i := range status.Teams {
+					status.Teams[i].Score = "0"
+				}
+				break
+			}
+		}
+	}
+	status.NumPlayers = uint8(len(status.Players))
+}
+
+// Simulation returns a Simulation with count random servers listed by a
+// master at master, changing on every query through Update.  The master
+// reply needs one packet per about 140 servers; raise Limits.MaxPackets
+// for counts above DefaultLimits allows.
+func (g *Generator) Simulation(master string, count int) (s *Simulation) {
+	s = NewSimulation()
+	list := MasterServerStatus{Name: "Synthetic Master", MOTD: "Synthetic data for testing"}
+	for _, status := range g.Statuses(count) {
+		s.SetServer(status.Address, status)
+		list.Servers = append(list.Servers, status.Address)
+	}
+	s.SetMaster(master, list)
+	s.SetUpdate(g.Update)
+	return
+}
+
+// player returns a random player on one of teams, with index making the
+// name unique within a server.
+func (g *Generator) player(index int, teams int) Player {
+	name := g.pick(syntheticPlayers) + strconv.Itoa(index)
+	if tag := g.pick(syntheticTags); len(tag) > 0 {
+		name = tag + name
+	}
+	team := uint8(g.rand.Intn(teams))
+	if g.rand.Intn(20) == 0 {
+		team = 255
+	}
+	return Player{
+		Name:  name,
+		Team:  team,
+		Score: strconv.Itoa(g.rand.Intn(40)),
+		Ping:  uint8(20 + g.rand.Intn(180)),
+		PL:    uint8(g.rand.Intn(3)),
+	}
+}
+
+func (g *Generator) pick(choices []string) string {
+	return choices[g.rand.Intn(len(choices))]
+}
+
+// NewGenerator returns a Generator seeded with seed.
+func NewGenerator(seed int64) *Generator {
+	return &Generator{rand: rand.New(rand.NewSource(seed))}
+}
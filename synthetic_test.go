@@ -0,0 +1,65 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGenerator(t *testing.T) {
+	statuses, replies, err := NewGenerator(1).Replies(200, 0x1234)
+	if err != nil {
+		t.Fatalf("Replies(): %v", err)
+	}
+	again := NewGenerator(1).Statuses(200)
+	if !reflect.DeepEqual(statuses, again) {
+		t.Errorf("Statuses(): Same seed produced different statuses")
+	}
+
+	for i, reply := range replies {
+		g := NewGameServer(statuses[i].Address)
+		err = g.LoadReply(reply)
+		if err != nil {
+			t.Fatalf("LoadReply(%v): %v", statuses[i].Address, err)
+		}
+		status := g.Status()
+		if status.Name != statuses[i].Name || status.Mission != statuses[i].Mission || !reflect.DeepEqual(status.Players, statuses[i].Players) {
+			t.Errorf("LoadReply(%v): %+v != %+v", statuses[i].Address, status, statuses[i])
+		}
+		if len(status.Players) > int(status.MaxPlayers) {
+			t.Errorf("len(Players): %v > %v", len(status.Players), status.MaxPlayers)
+		}
+	}
+}
+
+func TestGeneratorSimulation(t *testing.T) {
+	b := NewServerBrowser("master.example")
+	b.SetSimulation(NewGenerator(2).Simulation("master.example", 50))
+	b.SetTimeout(time.Second)
+	for i := 0; i < 3; i++ {
+		err := b.Refresh(context.Background())
+		if err != nil {
+			t.Fatalf("Refresh(): %v", err)
+		}
+		if statuses := b.Statuses(); len(statuses) != 50 {
+			t.Errorf("len(Statuses()): %v != 50", len(statuses))
+		}
+	}
+}
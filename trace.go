@@ -0,0 +1,143 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// TracePacket is one packet sent or received during a traced query.
+type TracePacket struct {
+	Time time.Time
+	// Sent is true for requests and false for replies.
+	Sent    bool
+	Address string
+	Data    []byte
+}
+
+// QueryTrace records what happened during one query, so it can be attached
+// to bug reports about a server that misbehaves.
+type QueryTrace struct {
+	Address string
+	// Start is when the query began, Resolved when the address had been
+	// resolved and Done when the query returned.
+	Start    time.Time
+	Resolved time.Time
+	Done     time.Time
+	// Candidates are the addresses the host resolved to.
+	Candidates []string
+	// Attempts is the number of requests sent, counting fallbacks to other
+	// candidates and burst probes.
+	Attempts int
+	Packets  []TracePacket
+	// Err is the error the query returned, if any.
+	Err error
+
+	ctx       context.Context
+	hook      PacketHook
+	recording bool
+}
+
+// PacketHook is called for every packet a query sends or receives, with the
+// context the query was made with and the address of the server queried, so
+// shared services can log the traffic and correlate it with the request
+// that caused it through values of ctx such as a request ID.  It may be
+// called from several goroutines, one at a time, and must not block.
+type PacketHook func(ctx context.Context, address string, packet TracePacket)
+
+// Context returns the context the traced query was made with, to read
+// values such as a request ID from.  It may be done by the time the trace is
+// read.
+func (t *QueryTrace) Context() context.Context {
+	if t.ctx == nil {
+		return context.Background()
+	}
+	return t.ctx
+}
+
+// String formats the trace with a hex dump of every packet.
+func (t *QueryTrace) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "query %s started %s\n", t.Address, t.Start.Format(time.RFC3339Nano))
+	if !t.Resolved.IsZero() {
+		fmt.Fprintf(&builder, "+%s resolved to %s\n", t.Resolved.Sub(t.Start), strings.Join(t.Candidates, ", "))
+	}
+	for _, packet := range t.Packets {
+		direction := "received from"
+		if packet.Sent {
+			direction = "sent to"
+		}
+		fmt.Fprintf(&builder, "+%s %d bytes %s %s\n", packet.Time.Sub(t.Start), len(packet.Data), direction, packet.Address)
+		builder.WriteString(hex.Dump(packet.Data))
+	}
+	fmt.Fprintf(&builder, "+%s done after %d attempts", t.Done.Sub(t.Start), t.Attempts)
+	if t.Err != nil {
+		fmt.Fprintf(&builder, ": %v", t.Err)
+	}
+	builder.WriteString("\n")
+	return builder.String()
+}
+
+// newTrace returns a trace of a query to address made with ctx, or nil if
+// tracing is disabled and there is no hook.  Packets are passed to hook,
+// which may be nil, and only recorded if enabled is set.  The other trace
+// methods do nothing on a nil trace.
+func newTrace(ctx context.Context, enabled bool, hook PacketHook, address string) *QueryTrace {
+	if !enabled && hook == nil {
+		return nil
+	}
+	return &QueryTrace{Address: address, Start: time.Now(), ctx: ctx, hook: hook, recording: enabled}
+}
+
+// resolved records the candidates address resolved to.  Only the first
+// attempt to resolve it is recorded; retries look the address up again.
+func (t *QueryTrace) resolved(candidates []*net.UDPAddr) {
+	if t == nil || !t.Resolved.IsZero() {
+		return
+	}
+	t.Resolved = time.Now()
+	for _, candidate := range candidates {
+		t.Candidates = append(t.Candidates, candidate.String())
+	}
+}
+
+// packet records a copy of data, sent to or received from address.
+func (t *QueryTrace) packet(sent bool, address net.Addr, data []byte) {
+	if t == nil {
+		return
+	}
+	if sent {
+		t.Attempts++
+	}
+	packet := TracePacket{
+		Time:    time.Now(),
+		Sent:    sent,
+		Address: address.String(),
+		Data:    append([]byte(nil), data...),
+	}
+	if t.hook != nil {
+		t.hook(t.ctx, t.Address, packet)
+	}
+	if t.recording {
+		t.Packets = append(t.Packets, packet)
+	}
+}
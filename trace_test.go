@@ -0,0 +1,180 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGameServerTrace(t *testing.T) {
+	status := GameServerStatus{Name: "Traced", Game: "Tribes", Version: "1.11"}
+	closer := replyOnce(t, "127.0.0.1:28984", func(key uint16) []byte {
+		var buffer bytes.Buffer
+		if err := status.WriteReply(&buffer, key); err != nil {
+			t.Error(err)
+		}
+		return buffer.Bytes()
+	})
+	defer closer()
+
+	game := NewGameServer("127.0.0.1:28984")
+	if game.Trace() != nil {
+		t.Fatal("game.Trace() != nil before any query")
+	}
+	game.SetTracing(true)
+	err := game.Query(time.Second, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trace := game.Trace()
+	if trace == nil {
+		t.Fatal("game.Trace() == nil")
+	}
+	if trace.Address != "127.0.0.1:28984" || len(trace.Candidates) != 1 || trace.Attempts != 1 || trace.Err != nil {
+		t.Errorf("trace: %+v", trace)
+	}
+	if len(trace.Packets) != 2 || !trace.Packets[0].Sent || trace.Packets[1].Sent {
+		t.Fatalf("trace.Packets: %+v", trace.Packets)
+	}
+	if len(trace.Packets[0].Data) != 3 || trace.Packets[0].Data[0] != 0x62 || trace.Packets[1].Data[0] != 0x63 {
+		t.Errorf("trace.Packets: %+v", trace.Packets)
+	}
+	if trace.Resolved.Before(trace.Start) || trace.Done.Before(trace.Packets[1].Time) {
+		t.Errorf("trace phases out of order: %+v", trace)
+	}
+	if dump := trace.String(); !strings.Contains(dump, "sent to 127.0.0.1:28984") || !strings.Contains(dump, "done after 1 attempts") {
+		t.Errorf("trace.String(): %s", dump)
+	}
+}
+
+// ignoreFirst serves replies to every request but the first, which goes
+// unanswered so the query has to retry.  keyAt is where the key is in the
+// request.
+func ignoreFirst(t *testing.T, keyAt int, reply func(key uint16) [][]byte) (address string) {
+	c, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+
+	go func() {
+		readBuffer := make([]byte, 64)
+		for request := 0; ; request++ {
+			n, addr, err := c.ReadFromUDP(readBuffer)
+			if err != nil {
+				return
+			}
+			if request == 0 || n < keyAt+2 {
+				continue
+			}
+			for _, packet := range reply(binary.BigEndian.Uint16(readBuffer[keyAt : keyAt+2])) {
+				if _, err = c.WriteToUDP(packet, addr); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return c.LocalAddr().String()
+}
+
+func TestTraceRetries(t *testing.T) {
+	status := GameServerStatus{Name: "Retried", Game: "Tribes", Version: "1.11"}
+	game := NewGameServer(ignoreFirst(t, 1, func(key uint16) [][]byte {
+		var buffer bytes.Buffer
+		if err := status.WriteReply(&buffer, key); err != nil {
+			t.Error(err)
+		}
+		return [][]byte{buffer.Bytes()}
+	}))
+	game.SetTracing(true)
+	game.SetRetries(1, 0)
+	if err := game.QueryWith(context.Background(), WithQueryTimeout(100*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	trace := game.Trace()
+	if trace.Attempts != 2 || len(trace.Packets) != 3 || len(trace.Candidates) != 1 || trace.Err != nil {
+		t.Errorf("game.Trace(): %+v", trace)
+	}
+
+	list := MasterServerStatus{Name: "Retried", Servers: []string{"192.0.2.1:28001"}}
+	master := NewMasterServer(ignoreFirst(t, 4, func(key uint16) [][]byte {
+		packets, err := list.WriteReply(key)
+		if err != nil {
+			t.Error(err)
+		}
+		return packets
+	}))
+	master.SetTracing(true)
+	master.SetRetries(1, 0)
+	if err := master.QueryWith(context.Background(), WithQueryTimeout(100*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	trace = master.Trace()
+	if trace.Attempts != 2 || len(trace.Packets) != 3 || len(trace.Candidates) != 1 || trace.Err != nil {
+		t.Errorf("master.Trace(): %+v", trace)
+	}
+}
+
+type requestIDKey struct{}
+
+func TestPacketHook(t *testing.T) {
+	status := GameServerStatus{Name: "Hooked", Game: "Tribes", Version: "1.11"}
+	dial := pipeDialer(func(request []byte) [][]byte {
+		var buffer bytes.Buffer
+		if err := status.WriteReply(&buffer, binary.BigEndian.Uint16(request[1:3])); err != nil {
+			t.Error(err)
+		}
+		return [][]byte{buffer.Bytes()}
+	})
+
+	var packets []TracePacket
+	hook := func(ctx context.Context, address string, packet TracePacket) {
+		if id := ctx.Value(requestIDKey{}); id != "request-1" || address != "192.0.2.1:28001" {
+			t.Errorf("hook(): %v, %v", id, address)
+		}
+		packets = append(packets, packet)
+	}
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "request-1")
+	game := NewGameServer("192.0.2.1:28001")
+	err := game.QueryWith(ctx, WithDialer(dial), WithPacketHook(hook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packets) != 2 || !packets[0].Sent || packets[1].Sent {
+		t.Errorf("hook() packets: %+v", packets)
+	}
+	if game.Trace() != nil {
+		t.Errorf("game.Trace() != nil without tracing")
+	}
+
+	game.SetTracing(true)
+	err = game.QueryWith(ctx, WithDialer(dial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := game.Trace().Context().Value(requestIDKey{}); id != "request-1" {
+		t.Errorf("game.Trace().Context() request ID: %v", id)
+	}
+}
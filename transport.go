@@ -0,0 +1,219 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Transport carries the datagrams of queries made with WithTransport, for
+// environments without raw UDP sockets, such as js/wasm or a relay.
+// UDPTransport is the implementation over a UDP socket.
+type Transport interface {
+	// Send sends packet to address, a "host:port" string as formatted by
+	// net.UDPAddr.
+	Send(packet []byte, address string) error
+	// Receive reads the next packet into packet, returning its length and
+	// the address it came from in the form Send takes.  It must return
+	// ctx.Err() once ctx is done.
+	Receive(ctx context.Context, packet []byte) (n int, address string, err error)
+	Close() error
+}
+
+// WithTransport sends the query over t instead of a socket of its own, like
+// WithConn.  Replies from other addresses are skipped and t is left open,
+// so queries sharing t should not run at the same time; Client is built for
+// that.
+func WithTransport(t Transport) QueryOption {
+	return func(options *queryOptions) {
+		options.conn = &transportConn{transport: t}
+	}
+}
+
+// UDPTransport is a Transport over a UDP socket, the way queries travel
+// without one.
+type UDPTransport struct {
+	conn *net.UDPConn
+}
+
+func (t *UDPTransport) Send(packet []byte, address string) (err error) {
+	remoteAddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return
+	}
+	_, err = t.conn.WriteToUDP(packet, remoteAddr)
+	return
+}
+
+func (t *UDPTransport) Receive(ctx context.Context, packet []byte) (n int, address string, err error) {
+	n, addr, err := readUDP(ctx, t.conn, packet)
+	if err != nil {
+		return
+	}
+	return n, addr.String(), nil
+}
+
+// readUDP reads a packet from c into p, giving up with ctx.Err() once ctx
+// is done.
+func readUDP(ctx context.Context, c *net.UDPConn, p []byte) (n int, addr *net.UDPAddr, err error) {
+	deadline, _ := ctx.Deadline()
+	if err = c.SetReadDeadline(deadline); err != nil {
+		return
+	}
+	defer stopOnDone(ctx, c, false)()
+	n, addr, err = c.ReadFromUDP(p)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+	}
+	return
+}
+
+func (t *UDPTransport) Close() (err error) {
+	return t.conn.Close()
+}
+
+// LocalAddr returns the address the transport's socket is bound to.
+func (t *UDPTransport) LocalAddr() net.Addr {
+	return t.conn.LocalAddr()
+}
+
+// NewUDPTransport returns a UDPTransport with a socket bound to
+// localAddress, or to any local address if it is empty.
+func NewUDPTransport(localAddress string) (t *UDPTransport, err error) {
+	var localAddr *net.UDPAddr
+	if len(localAddress) != 0 {
+		localAddr, err = net.ResolveUDPAddr("udp", localAddress)
+		if err != nil {
+			return
+		}
+	}
+	conn, err := net.ListenUDP("udp", localAddr)
+	if err != nil {
+		return
+	}
+	return &UDPTransport{conn: conn}, nil
+}
+
+// transportAddr is the address of a packet received over a Transport that
+// is not an IP address and port.
+type transportAddr string
+
+func (a transportAddr) Network() string {
+	return "transport"
+}
+
+func (a transportAddr) String() string {
+	return string(a)
+}
+
+// transportConn adapts a Transport to net.PacketConn for the query code.
+// Moving the read deadline into the past interrupts a Receive in progress,
+// as it does a read on a socket; other moves apply to the next Receive.
+type transportConn struct {
+	transport Transport
+
+	mutex    sync.Mutex
+	deadline time.Time
+	cancel   context.CancelFunc
+}
+
+func (c *transportConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	c.mutex.Lock()
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if c.deadline.IsZero() {
+		ctx, cancel = context.WithCancel(context.Background())
+	} else {
+		ctx, cancel = context.WithDeadline(context.Background(), c.deadline)
+	}
+	c.cancel = cancel
+	c.mutex.Unlock()
+	defer cancel()
+
+	n, address, err := c.transport.Receive(ctx, p)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			err = os.ErrDeadlineExceeded
+		}
+		return
+	}
+	return n, parseTransportAddr(address), nil
+}
+
+func (c *transportConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	err = c.transport.Send(p, addr.String())
+	if err != nil {
+		return
+	}
+	return len(p), nil
+}
+
+// Close leaves the transport open: it belongs to the caller.
+func (c *transportConn) Close() error {
+	return nil
+}
+
+func (c *transportConn) LocalAddr() net.Addr {
+	return transportAddr("")
+}
+
+func (c *transportConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *transportConn) SetReadDeadline(t time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.deadline = t
+	if c.cancel != nil && !t.IsZero() && !t.After(time.Now()) {
+		c.cancel()
+		c.cancel = nil
+	}
+	return nil
+}
+
+func (c *transportConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// parseTransportAddr returns address as a *net.UDPAddr if it is an IP
+// address and port, or as a transportAddr otherwise.
+func parseTransportAddr(address string) net.Addr {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return transportAddr(address)
+	}
+	ip := net.ParseIP(host)
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if ip == nil || err != nil {
+		return transportAddr(address)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return &net.UDPAddr{IP: ip, Port: int(port)}
+}
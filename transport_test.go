@@ -0,0 +1,119 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// relayTransport is an in-memory Transport whose packets are answered by
+// reply, standing in for a relay.
+type relayTransport struct {
+	reply   func(packet []byte, address string) []byte
+	replies chan [2]string
+}
+
+func (t *relayTransport) Send(packet []byte, address string) error {
+	if reply := t.reply(packet, address); reply != nil {
+		t.replies <- [2]string{string(reply), address}
+	}
+	return nil
+}
+
+func (t *relayTransport) Receive(ctx context.Context, packet []byte) (n int, address string, err error) {
+	select {
+	case reply := <-t.replies:
+		return copy(packet, reply[0]), reply[1], nil
+	case <-ctx.Done():
+		return 0, "", ctx.Err()
+	}
+}
+
+func (t *relayTransport) Close() error {
+	return nil
+}
+
+func TestQueryWithTransport(t *testing.T) {
+	status := GameServerStatus{Name: "Relayed", Game: "Tribes", Version: "1.41"}
+	transport := &relayTransport{replies: make(chan [2]string, 4)}
+	transport.reply = func(packet []byte, address string) []byte {
+		if address != "192.0.2.1:28001" {
+			return nil
+		}
+		var buffer bytes.Buffer
+		if err := status.WriteReply(&buffer, binary.BigEndian.Uint16(packet[1:3])); err != nil {
+			t.Error(err)
+		}
+		return buffer.Bytes()
+	}
+
+	game := NewGameServer("192.0.2.1")
+	err := game.QueryWith(context.Background(), WithTransport(transport), WithQueryTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("QueryWith(): %v", err)
+	}
+	if game.Name() != "Relayed" {
+		t.Errorf("Name(): %v != Relayed", game.Name())
+	}
+
+	silent := NewGameServer("192.0.2.2")
+	err = silent.QueryWith(context.Background(), WithTransport(transport), WithQueryTimeout(20*time.Millisecond))
+	var netErr interface{ Timeout() bool }
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("QueryWith() silent server: %v is not a timeout", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	err = silent.QueryWith(ctx, WithTransport(transport), WithQueryTimeout(5*time.Second))
+	if !errors.Is(err, context.Canceled) || time.Since(start) > time.Second {
+		t.Errorf("QueryWith() cancelled: %v after %s", err, time.Since(start))
+	}
+}
+
+func TestUDPTransport(t *testing.T) {
+	status := GameServerStatus{Name: "UDP", Game: "Tribes", Version: "1.41"}
+	closer := replyOnce(t, "127.0.0.1:28956", func(key uint16) []byte {
+		var buffer bytes.Buffer
+		if err := status.WriteReply(&buffer, key); err != nil {
+			t.Error(err)
+		}
+		return buffer.Bytes()
+	})
+	defer closer()
+
+	transport, err := NewUDPTransport("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+
+	game := NewGameServer("127.0.0.1:28956")
+	err = game.QueryWith(context.Background(), WithTransport(transport), WithQueryTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("QueryWith(): %v", err)
+	}
+	if game.Name() != "UDP" {
+		t.Errorf("Name(): %v != UDP", game.Name())
+	}
+}
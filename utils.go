@@ -24,8 +24,82 @@ import (
 	"io"
 	"net"
 	"strings"
+	"sync"
 )
 
+// ErrRefreshLimited is returned by Refresh when it is called again before the
+// minimum refresh interval has passed.
+var ErrRefreshLimited = errors.New("t1net: Refreshed too recently")
+
+// MalformedReplyError is returned instead of panicking when parsing a reply
+// fails in a way the parser did not anticipate.
+type MalformedReplyError struct {
+	// Packet is a copy of the reply that was being parsed.
+	Packet []byte
+	// Panic is the value the parser panicked with.
+	Panic interface{}
+}
+
+func (e *MalformedReplyError) Error() string {
+	return fmt.Sprintf("t1net: Malformed %d byte reply: %v", len(e.Packet), e.Panic)
+}
+
+// recoverMalformed turns a panic while parsing packet into a
+// MalformedReplyError stored in err.  It must be deferred directly.
+func recoverMalformed(packet []byte, err *error) {
+	if r := recover(); r != nil {
+		*err = &MalformedReplyError{Packet: append([]byte(nil), packet...), Panic: r}
+	}
+}
+
+// StringPool keeps a single copy of each string passed to Intern.  It is
+// safe for concurrent use, so one pool can be shared by every GameServer in
+// a scan.  Strings are never evicted; call Reset to release them.
+type StringPool struct {
+	mutex   sync.Mutex
+	strings map[string]string
+}
+
+// Intern returns the pooled copy of str, adding str if it is not yet pooled.
+// A nil pool returns str unchanged.
+func (p *StringPool) Intern(str string) string {
+	if p == nil || len(str) == 0 {
+		return str
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if pooled, ok := p.strings[str]; ok {
+		return pooled
+	}
+	if p.strings == nil {
+		p.strings = make(map[string]string)
+	}
+	p.strings[str] = str
+	return str
+}
+
+// Len returns how many distinct strings are pooled.
+func (p *StringPool) Len() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.strings)
+}
+
+// Reset releases every pooled string.  Strings already returned by Intern
+// stay valid.
+func (p *StringPool) Reset() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.strings = nil
+}
+
+// NewStringPool returns an empty StringPool.  The zero value is ready to
+// use too.
+func NewStringPool() *StringPool {
+	return &StringPool{}
+}
+
 func ReadPascalString(reader *bytes.Reader) (str string, err error) {
 	b, err := reader.ReadByte()
 	if err != nil {
@@ -46,6 +120,28 @@ func ReadPascalString(reader *bytes.Reader) (str string, err error) {
 	return
 }
 
+// readPascalSubstring is ReadPascalString for a reader created over the same
+// bytes as backing, returning a slice of backing rather than a new string.
+func readPascalSubstring(reader *bytes.Reader, backing string) (str string, err error) {
+	b, err := reader.ReadByte()
+	if err != nil {
+		return
+	}
+
+	start := len(backing) - reader.Len()
+	end := start + int(b)
+	if start < 0 || end > len(backing) {
+		err = io.EOF
+		return
+	}
+	_, err = reader.Seek(int64(b), io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	str = backing[start:end]
+	return
+}
+
 func WritePascalString(buffer *bytes.Buffer, str string) (err error) {
 	strlen := len(str)
 	if strlen > 255 {
@@ -102,3 +198,10 @@ func WriteAddressPort(buffer *bytes.Buffer, ip net.IP, port uint16) (err error)
 	}
 	return
 }
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
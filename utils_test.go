@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"net"
 	"testing"
+	"unsafe"
 )
 
 func TestReadPascalString(t *testing.T) {
@@ -33,6 +34,27 @@ func TestReadPascalString(t *testing.T) {
 	}
 }
 
+func TestReadPascalSubstring(t *testing.T) {
+	packet := []byte{7, 'T', 'e', 's', 't', 'i', 'n', 'g', 0, 4, 'T', 'e', 's', 't'}
+	backing := string(packet)
+	reader := bytes.NewReader(packet)
+	for _, expected := range []string{"Testing", "", "Test"} {
+		str, err := readPascalSubstring(reader, backing)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if str != expected {
+			t.Fatalf("%s != %s", str, expected)
+		}
+	}
+
+	reader = bytes.NewReader([]byte{7, 'T', 'e', 's'})
+	_, err := readPascalSubstring(reader, "\x07Tes")
+	if err == nil {
+		t.Fatal("expected an error for a truncated string")
+	}
+}
+
 func TestWritePascalString(t *testing.T) {
 	var buffer bytes.Buffer
 	err := WritePascalString(&buffer, "Testing")
@@ -69,3 +91,66 @@ func TestWriteAddressPort(t *testing.T) {
 		t.Fatalf("bytes.Equal failed: %v", buffer.Bytes())
 	}
 }
+
+func TestStringPool(t *testing.T) {
+	pool := NewStringPool()
+	a := pool.Intern(string([]byte("Raindance")))
+	b := pool.Intern(string([]byte("Raindance")))
+	if a != b {
+		t.Fatalf("%s != %s", a, b)
+	}
+	if pool.Len() != 1 {
+		t.Fatalf("pool.Len(): %d != 1", pool.Len())
+	}
+	pool.Reset()
+	if pool.Len() != 0 {
+		t.Fatalf("pool.Len(): %d != 0", pool.Len())
+	}
+
+	var nilPool *StringPool
+	if nilPool.Intern("Testing") != "Testing" {
+		t.Fatal("nil pool did not return the input string")
+	}
+}
+
+// stringData returns the address of the bytes backing str, the first word
+// of a string header.
+func stringData(str string) uintptr {
+	return *(*uintptr)(unsafe.Pointer(&str))
+}
+
+func TestGameServerStringPool(t *testing.T) {
+	status := GameServerStatus{
+		Game:    "Tribes",
+		Mission: "Raindance",
+		Teams:   []Team{{Name: "Blood Eagle", Score: "0"}},
+	}
+	pool := NewStringPool()
+	servers := make([]*GameServer, 2)
+	for i := range servers {
+		// Each server parses its own copy of the reply.
+		var buffer bytes.Buffer
+		if err := status.WriteReply(&buffer, 0); err != nil {
+			t.Fatal(err)
+		}
+		servers[i] = NewGameServer("127.0.0.1:28001")
+		servers[i].SetStringPool(pool)
+		if err := servers[i].LoadReply(buffer.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, b := servers[0].Status(), servers[1].Status()
+	if a.Mission != "Raindance" || stringData(a.Mission) != stringData(b.Mission) {
+		t.Errorf("Mission: %q and %q are not shared", a.Mission, b.Mission)
+	}
+	if stringData(a.Game) != stringData(b.Game) {
+		t.Errorf("Game: %q and %q are not shared", a.Game, b.Game)
+	}
+	if stringData(a.Teams[0].Name) != stringData(b.Teams[0].Name) {
+		t.Errorf("Teams[0].Name: %q and %q are not shared", a.Teams[0].Name, b.Teams[0].Name)
+	}
+	if pool.Len() == 0 {
+		t.Error("pool.Len(): 0 after parsing")
+	}
+}
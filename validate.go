@@ -0,0 +1,46 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Validate returns a description of every inconsistency in the status, or
+// nil if there are none.  Queries succeed with such statuses; Validate lets
+// list operators flag servers reporting nonsense.
+func (s *GameServerStatus) Validate() (issues []string) {
+	if len(strings.TrimSpace(s.Name)) == 0 {
+		issues = append(issues, "empty server name")
+	}
+	if int(s.NumPlayers) != len(s.Players) {
+		issues = append(issues, fmt.Sprintf("player count %d does not match %d players listed", s.NumPlayers, len(s.Players)))
+	}
+	if int(s.NumTeams) != len(s.Teams) {
+		issues = append(issues, fmt.Sprintf("team count %d does not match %d teams listed", s.NumTeams, len(s.Teams)))
+	}
+	if s.MaxPlayers < s.NumPlayers {
+		issues = append(issues, fmt.Sprintf("player count %d exceeds max players %d", s.NumPlayers, s.MaxPlayers))
+	}
+	return
+}
+
+func (g *GameServer) Validate() (issues []string) {
+	status := g.Status()
+	return status.Validate()
+}
@@ -0,0 +1,41 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import "testing"
+
+func TestGameServerStatusValidate(t *testing.T) {
+	status := GameServerStatus{
+		Name:       "Valid",
+		NumPlayers: 1,
+		MaxPlayers: 16,
+		NumTeams:   1,
+		Teams:      []Team{{Name: "Blood Eagle"}},
+		Players:    []Player{{Name: "Alpha"}},
+	}
+	if issues := status.Validate(); issues != nil {
+		t.Fatalf("valid status reported issues: %v", issues)
+	}
+
+	status.Name = " "
+	status.NumPlayers = 20
+	status.NumTeams = 2
+	issues := status.Validate()
+	if len(issues) != 4 {
+		t.Fatalf("len(issues): %d != 4: %v", len(issues), issues)
+	}
+}
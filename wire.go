@@ -0,0 +1,322 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The packet layouts below document the wire format as Go structs.  Each
+// field's `wire` tag gives its encoding, in order:
+//
+//	u8       one byte, for uint8 fields
+//	bool     one byte, 0 or 1
+//	u16be    two bytes, big endian, for uint16 fields
+//	u16le    two bytes, little endian, for uint16 fields
+//	pascal   a length byte followed by that many bytes, for string fields
+//	ip4      four bytes, for net.IP fields
+//	-        not on the wire
+//
+// along with options: const=N makes the field always N, checked when
+// decoding, and repeat=Field encodes a slice of structs as that many
+// elements, where Field is an earlier integer field holding the count.
+// MarshalPacket and UnmarshalPacket encode and decode any struct tagged
+// this way, so a new message type only needs a struct.
+
+// GameQueryRequest asks a game server for its status.
+type GameQueryRequest struct {
+	Type byte   `wire:"u8,const=0x62"`
+	Key  uint16 `wire:"u16be"`
+}
+
+// GameQueryReply is a game server's answer to a GameQueryRequest with the
+// same key.
+type GameQueryReply struct {
+	Type              byte              `wire:"u8,const=0x63"`
+	Key               uint16            `wire:"u16be"`
+	Marker            byte              `wire:"u8,const=0x62"`
+	Game              string            `wire:"pascal"`
+	Version           string            `wire:"pascal"`
+	Name              string            `wire:"pascal"`
+	Dedicated         bool              `wire:"bool"`
+	Password          bool              `wire:"bool"`
+	NumPlayers        uint8             `wire:"u8"`
+	MaxPlayers        uint8             `wire:"u8"`
+	CPUSpeed          uint16            `wire:"u16le"`
+	Mod               string            `wire:"pascal"`
+	ServerType        string            `wire:"pascal"`
+	Mission           string            `wire:"pascal"`
+	Info              string            `wire:"pascal"`
+	NumTeams          uint8             `wire:"u8"`
+	TeamScoreHeader   string            `wire:"pascal"`
+	PlayerScoreHeader string            `wire:"pascal"`
+	Teams             []GameQueryTeam   `wire:"repeat=NumTeams"`
+	Players           []GameQueryPlayer `wire:"repeat=NumPlayers"`
+}
+
+// GameQueryTeam is a team in a GameQueryReply.
+type GameQueryTeam struct {
+	Name  string `wire:"pascal"`
+	Score string `wire:"pascal"`
+}
+
+// GameQueryPlayer is a player in a GameQueryReply.
+type GameQueryPlayer struct {
+	Ping  uint8  `wire:"u8"`
+	PL    uint8  `wire:"u8"`
+	Team  uint8  `wire:"u8"`
+	Name  string `wire:"pascal"`
+	Score string `wire:"pascal"`
+}
+
+// MasterQueryRequest asks a master server for its server list.
+type MasterQueryRequest struct {
+	Version      byte   `wire:"u8,const=0x10"`
+	Type         byte   `wire:"u8,const=0x03"`
+	PacketNumber byte   `wire:"u8,const=0xFF"`
+	PacketTotal  byte   `wire:"u8,const=0x00"`
+	Key          uint16 `wire:"u16be"`
+	ClientID     uint16 `wire:"u16be"`
+}
+
+// MasterQueryReply is one packet of a master server's answer to a
+// MasterQueryRequest with the same key.
+type MasterQueryReply struct {
+	Version      byte                `wire:"u8,const=0x10"`
+	Type         byte                `wire:"u8,const=0x06"`
+	PacketNumber byte                `wire:"u8"`
+	PacketTotal  byte                `wire:"u8"`
+	Key          uint16              `wire:"u16be"`
+	Reserved     byte                `wire:"u8,const=0x00"`
+	Marker       byte                `wire:"u8,const=0x66"`
+	Name         string              `wire:"pascal"`
+	MOTD         string              `wire:"pascal"`
+	ServerCount  uint16              `wire:"u16be"`
+	Servers      []MasterQueryServer `wire:"repeat=ServerCount"`
+}
+
+// MasterQueryServer is a server listed in a MasterQueryReply.
+type MasterQueryServer struct {
+	Length byte   `wire:"u8,const=6"`
+	IP     net.IP `wire:"ip4"`
+	Port   uint16 `wire:"u16le"`
+}
+
+// wireField is a parsed `wire` tag.
+type wireField struct {
+	encoding string
+	constant *uint64
+	repeat   string
+}
+
+func parseWireTag(field reflect.StructField) (f wireField, err error) {
+	for _, option := range strings.Split(field.Tag.Get("wire"), ",") {
+		switch {
+		case !strings.Contains(option, "="):
+			f.encoding = option
+		case strings.HasPrefix(option, "const="):
+			var value uint64
+			value, err = strconv.ParseUint(strings.TrimPrefix(option, "const="), 0, 16)
+			if err != nil {
+				return f, fmt.Errorf("Invalid constant %q", option)
+			}
+			f.constant = &value
+		case strings.HasPrefix(option, "repeat="):
+			f.repeat = strings.TrimPrefix(option, "repeat=")
+		default:
+			return f, fmt.Errorf("Unknown option %q", option)
+		}
+	}
+	if f.encoding == "" && f.repeat == "" {
+		return f, fmt.Errorf("Missing wire tag")
+	}
+	return
+}
+
+// MarshalPacket encodes v, a struct or pointer to one with `wire` tags,
+// such as GameQueryRequest.  Constant fields are written with their
+// constant and the count of each repeated slice with the slice's length,
+// whatever v holds.
+func MarshalPacket(v interface{}) (packet []byte, err error) {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("t1net.MarshalPacket: %T is not a struct", v)
+	}
+	var buffer bytes.Buffer
+	if err = marshalStruct(&buffer, value); err != nil {
+		return nil, fmt.Errorf("t1net.MarshalPacket: %v", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+func marshalStruct(buffer *bytes.Buffer, value reflect.Value) (err error) {
+	// Work on a copy so counts can be filled in from the slices.
+	copied := reflect.New(value.Type()).Elem()
+	copied.Set(value)
+	value = copied
+
+	fields := make([]wireField, value.NumField())
+	for i := range fields {
+		structField := value.Type().Field(i)
+		if fields[i], err = parseWireTag(structField); err != nil {
+			return fmt.Errorf("Field %s: %v", structField.Name, err)
+		}
+		if fields[i].repeat != "" {
+			count := value.FieldByName(fields[i].repeat)
+			if !count.IsValid() || !count.CanSet() {
+				return fmt.Errorf("Field %s: No count field %s", structField.Name, fields[i].repeat)
+			}
+			length := uint64(value.Field(i).Len())
+			if count.OverflowUint(length) {
+				return fmt.Errorf("Field %s: %d elements overflow count field %s", structField.Name, length, fields[i].repeat)
+			}
+			count.SetUint(length)
+		}
+	}
+
+	for i, f := range fields {
+		field := value.Field(i)
+		name := value.Type().Field(i).Name
+		if f.constant != nil {
+			field.SetUint(*f.constant)
+		}
+		switch {
+		case f.encoding == "-":
+		case f.repeat != "":
+			for j := 0; j < field.Len(); j++ {
+				if err = marshalStruct(buffer, field.Index(j)); err != nil {
+					return fmt.Errorf("Field %s[%d]: %v", name, j, err)
+				}
+			}
+		case f.encoding == "u8":
+			buffer.WriteByte(byte(field.Uint()))
+		case f.encoding == "bool":
+			buffer.WriteByte(boolByte(field.Bool()))
+		case f.encoding == "u16be":
+			err = binary.Write(buffer, binary.BigEndian, uint16(field.Uint()))
+		case f.encoding == "u16le":
+			err = binary.Write(buffer, binary.LittleEndian, uint16(field.Uint()))
+		case f.encoding == "pascal":
+			err = WritePascalString(buffer, field.String())
+		case f.encoding == "ip4":
+			ip := net.IP(field.Bytes()).To4()
+			if ip == nil {
+				return fmt.Errorf("Field %s: %v is not an IPv4 address", name, net.IP(field.Bytes()))
+			}
+			buffer.Write(ip)
+		default:
+			return fmt.Errorf("Field %s: Unknown encoding %q", name, f.encoding)
+		}
+		if err != nil {
+			return fmt.Errorf("Field %s: %v", name, err)
+		}
+	}
+	return
+}
+
+// UnmarshalPacket decodes packet into v, a pointer to a struct with `wire`
+// tags such as *GameQueryReply.  It fails if a constant field holds another
+// value or bytes are left over.
+func UnmarshalPacket(packet []byte, v interface{}) (err error) {
+	defer recoverMalformed(packet, &err)
+
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("t1net.UnmarshalPacket: %T is not a pointer to a struct", v)
+	}
+	reader := bytes.NewReader(packet)
+	if err = unmarshalStruct(reader, value.Elem()); err != nil {
+		return fmt.Errorf("t1net.UnmarshalPacket: %v", err)
+	}
+	if reader.Len() != 0 {
+		return fmt.Errorf("t1net.UnmarshalPacket: %d left over bytes", reader.Len())
+	}
+	return
+}
+
+func unmarshalStruct(reader *bytes.Reader, value reflect.Value) (err error) {
+	for i := 0; i < value.NumField(); i++ {
+		structField := value.Type().Field(i)
+		field, name := value.Field(i), structField.Name
+		var f wireField
+		f, err = parseWireTag(structField)
+		if err != nil {
+			return fmt.Errorf("Field %s: %v", name, err)
+		}
+
+		switch {
+		case f.encoding == "-":
+		case f.repeat != "":
+			count := value.FieldByName(f.repeat)
+			if !count.IsValid() {
+				return fmt.Errorf("Field %s: No count field %s", name, f.repeat)
+			}
+			n := int(count.Uint())
+			field.Set(reflect.MakeSlice(field.Type(), n, n))
+			for j := 0; j < n; j++ {
+				if err = unmarshalStruct(reader, field.Index(j)); err != nil {
+					return fmt.Errorf("Field %s[%d]: %v", name, j, err)
+				}
+			}
+		case f.encoding == "u8" || f.encoding == "bool":
+			var b byte
+			if b, err = reader.ReadByte(); err != nil {
+				break
+			}
+			if f.encoding == "bool" {
+				field.SetBool(b != 0)
+			} else {
+				field.SetUint(uint64(b))
+			}
+		case f.encoding == "u16be" || f.encoding == "u16le":
+			var order binary.ByteOrder = binary.BigEndian
+			if f.encoding == "u16le" {
+				order = binary.LittleEndian
+			}
+			var n uint16
+			if err = binary.Read(reader, order, &n); err == nil {
+				field.SetUint(uint64(n))
+			}
+		case f.encoding == "pascal":
+			var str string
+			if str, err = ReadPascalString(reader); err == nil {
+				field.SetString(str)
+			}
+		case f.encoding == "ip4":
+			ip := make(net.IP, net.IPv4len)
+			if _, err = io.ReadFull(reader, ip); err == nil {
+				field.Set(reflect.ValueOf(ip))
+			}
+		default:
+			return fmt.Errorf("Field %s: Unknown encoding %q", name, f.encoding)
+		}
+		if err != nil {
+			return fmt.Errorf("Field %s: %v", name, err)
+		}
+		if f.constant != nil && field.Uint() != *f.constant {
+			return fmt.Errorf("Field %s: %#x != %#x", name, field.Uint(), *f.constant)
+		}
+	}
+	return
+}
@@ -0,0 +1,163 @@
+/*
+   Copyright 2022 Max Krivanek
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package t1net
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalPacket(t *testing.T) {
+	request, err := MarshalPacket(GameQueryRequest{Key: 0x1234})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(request, []byte{0x62, 0x12, 0x34}) {
+		t.Errorf("MarshalPacket(GameQueryRequest): %v != [98 18 52]", request)
+	}
+
+	request, err = MarshalPacket(&MasterQueryRequest{Key: 0x1234, ClientID: 0x0102})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(request, []byte{0x10, 0x03, 0xFF, 0x00, 0x12, 0x34, 0x01, 0x02}) {
+		t.Errorf("MarshalPacket(MasterQueryRequest): %v", request)
+	}
+
+	_, err = MarshalPacket(MasterQueryServer{IP: net.ParseIP("::1")})
+	if err == nil {
+		t.Error("MarshalPacket(MasterQueryServer): expected an error for an IPv6 address")
+	}
+	_, err = MarshalPacket(GameQueryReply{Players: make([]GameQueryPlayer, 300)})
+	if err == nil {
+		t.Error("MarshalPacket(GameQueryReply): expected an error for 300 players")
+	}
+	_, err = MarshalPacket(42)
+	if err == nil {
+		t.Error("MarshalPacket(42): expected an error")
+	}
+}
+
+func TestUnmarshalPacketGameReply(t *testing.T) {
+	status := GameServerStatus{
+		Game:       "Tribes",
+		Name:       "Wire",
+		Dedicated:  true,
+		NumPlayers: 1,
+		MaxPlayers: 32,
+		CPUSpeed:   450,
+		Mission:    "Raindance",
+		NumTeams:   2,
+		Teams:      []Team{{Name: "Blood Eagle", Score: "1"}, {Name: "Diamond Sword", Score: "2"}},
+		Players:    []Player{{Name: "Alpha", Team: 1, Score: "3", Ping: 40, PL: 2}},
+	}
+	var buffer bytes.Buffer
+	err := status.WriteReply(&buffer, 0x1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reply GameQueryReply
+	err = UnmarshalPacket(buffer.Bytes(), &reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Key != 0x1234 || reply.Name != "Wire" || reply.CPUSpeed != 450 || !reply.Dedicated || reply.Mission != "Raindance" {
+		t.Errorf("UnmarshalPacket(): %+v", reply)
+	}
+	if len(reply.Teams) != 2 || reply.Teams[1].Name != "Diamond Sword" {
+		t.Errorf("reply.Teams: %v", reply.Teams)
+	}
+	if len(reply.Players) != 1 || reply.Players[0] != (GameQueryPlayer{Ping: 40, PL: 2, Team: 1, Name: "Alpha", Score: "3"}) {
+		t.Errorf("reply.Players: %v", reply.Players)
+	}
+
+	// Encoding the decoded reply gives back the same bytes.
+	packet, err := MarshalPacket(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(packet, buffer.Bytes()) {
+		t.Errorf("MarshalPacket(): %v != %v", packet, buffer.Bytes())
+	}
+
+	err = UnmarshalPacket(buffer.Bytes()[0:10], &reply)
+	if err == nil {
+		t.Error("UnmarshalPacket(): expected an error for a truncated reply")
+	}
+	corrupt := append([]byte(nil), buffer.Bytes()...)
+	corrupt[3] = 0x61
+	err = UnmarshalPacket(corrupt, &reply)
+	if err == nil {
+		t.Error("UnmarshalPacket(): expected an error for a wrong marker")
+	}
+	err = UnmarshalPacket(append(buffer.Bytes(), 0), &reply)
+	if err == nil {
+		t.Error("UnmarshalPacket(): expected an error for left over bytes")
+	}
+}
+
+func TestUnmarshalPacketMasterReply(t *testing.T) {
+	status := MasterServerStatus{
+		Name:    "Master",
+		MOTD:    "MOTD",
+		Servers: []string{"12.13.14.15:28001", "22.23.24.25:28002"},
+	}
+	packets, err := status.WriteReply(0x1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packets) != 1 {
+		t.Fatalf("len(packets): %d != 1", len(packets))
+	}
+
+	var reply MasterQueryReply
+	err = UnmarshalPacket(packets[0], &reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := MasterQueryReply{
+		Version:      0x10,
+		Type:         0x06,
+		PacketNumber: 1,
+		PacketTotal:  1,
+		Key:          0x1234,
+		Marker:       0x66,
+		Name:         "Master",
+		MOTD:         "MOTD",
+		ServerCount:  2,
+		Servers: []MasterQueryServer{
+			{Length: 6, IP: net.IP{12, 13, 14, 15}, Port: 28001},
+			{Length: 6, IP: net.IP{22, 23, 24, 25}, Port: 28002},
+		},
+	}
+	if !reflect.DeepEqual(reply, expected) {
+		t.Errorf("UnmarshalPacket(): %+v != %+v", reply, expected)
+	}
+
+	// Counts and constants come from the slices and tags, not the fields.
+	expected.ServerCount, expected.Version = 0, 0
+	packet, err := MarshalPacket(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(packet, packets[0]) {
+		t.Errorf("MarshalPacket(): %v != %v", packet, packets[0])
+	}
+}